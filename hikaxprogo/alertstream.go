@@ -0,0 +1,267 @@
+package hikaxprogo
+
+import (
+	"bytes"
+	"context"
+	json "encoding/json"
+	xml "encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Kinds of events delivered by AlertStream
+const (
+	EventZoneAlarm   = "zoneAlarm"
+	EventTamper      = "tamper"
+	EventArm         = "arm"
+	EventDisarm      = "disarm"
+	EventFault       = "fault"
+	EventHeartbeat   = "heartbeat"
+	EventOther       = "other"
+	EventStreamError = "streamError"
+)
+
+const (
+	alertStreamIdle       = 2 * time.Minute  // reconnect if the panel sends nothing, not even a heartbeat
+	alertStreamMinBackoff = 1 * time.Second  // first reconnect delay
+	alertStreamMaxBackoff = 30 * time.Second // reconnect delay cap
+)
+
+// EventNotification is a single part of the alert stream as sent by the panel
+type EventNotification struct {
+	XMLName          xml.Name  `json:"-" xml:"EventNotificationAlert"`
+	IPAddress        string    `json:"ipAddress" xml:"ipAddress"`
+	MacAddress       string    `json:"macAddress" xml:"macAddress"`
+	ChannelID        int       `json:"channelID" xml:"channelID"`
+	DateTime         string    `json:"dateTime" xml:"dateTime"`
+	ActivePostCount  int       `json:"activePostCount" xml:"activePostCount"`
+	EventType        string    `json:"eventType" xml:"eventType"`
+	EventState       string    `json:"eventState" xml:"eventState"`
+	EventDescription string    `json:"eventDescription" xml:"eventDescription"`
+	CIDEvent         *CIDEvent `json:"CIDEvent" xml:"CIDEvent"`
+}
+
+// CIDEvent is the Contact ID payload of alarm panel events
+type CIDEvent struct {
+	Code            int    `json:"code" xml:"code"`
+	StandardCIDcode int    `json:"standardCIDcode" xml:"standardCIDcode"`
+	Type            string `json:"type" xml:"type"`
+	Trigger         string `json:"trigger" xml:"trigger"`
+	Upload          string `json:"upload" xml:"upload"`
+	Zone            int    `json:"zone" xml:"zone"`
+	ZoneName        string `json:"zoneName" xml:"zoneName"`
+	System          int    `json:"system" xml:"system"`
+	SubSysName      string `json:"subSysName" xml:"subSysName"`
+	UserName        string `json:"userName" xml:"userName"`
+}
+
+// AlertEvent is a typed event from the alert stream
+type AlertEvent struct {
+	Kind        string
+	Time        time.Time
+	Code        int  // Contact ID code, e.g. 1130 for burglary
+	Restore     bool // the event restores (ends) a previous condition
+	Zone        int
+	ZoneName    string
+	SubSystem   int
+	User        string
+	Description string
+	Err         error // set for EventStreamError
+	Raw         EventNotification
+}
+
+// AlertStream subscribes to the panel event stream and delivers parsed events on the
+// returned channel. The stream reconnects, logging in again when the session expires,
+// until ctx is cancelled; then the channel is closed. Connection problems are reported
+// as EventStreamError events.
+func (hik *HikISAPI) AlertStream(ctx context.Context) <-chan AlertEvent {
+	events := make(chan AlertEvent)
+	go func() {
+		defer close(events)
+		backoff := alertStreamMinBackoff
+		for {
+			connected, err := hik.readAlertStream(ctx, events)
+			if ctx.Err() != nil {
+				return
+			}
+			if connected {
+				backoff = alertStreamMinBackoff
+			}
+			select {
+			case events <- AlertEvent{Kind: EventStreamError, Time: time.Now(), Err: err}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff *= 2
+			if backoff > alertStreamMaxBackoff {
+				backoff = alertStreamMaxBackoff
+			}
+		}
+	}()
+	return events
+}
+
+// readAlertStream reads the stream until it breaks, connected reports whether the panel accepted it
+func (hik *HikISAPI) readAlertStream(ctx context.Context, events chan<- AlertEvent) (connected bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the panel sends heartbeats, a silent connection is a dead one; the timer runs
+	// from the start, so a panel that accepts the connection but never answers is dropped too
+	idle := time.AfterFunc(alertStreamIdle, cancel)
+	defer idle.Stop()
+	resp, err := hik.openAlertStream(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		data, _ := readBody(resp.Body, AlertStream)
+		return false, responseError(resp.StatusCode, AlertStream, data)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false, fmt.Errorf("alert stream: %w", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return false, fmt.Errorf("alert stream: unexpected content type %s", mediaType)
+	}
+
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("alert stream: closed by panel")
+			}
+			return true, err
+		}
+		idle.Reset(alertStreamIdle)
+
+		data, err := readBody(part, AlertStream)
+		if errors.Is(err, ErrResponseTooLarge) {
+			// the rest of the part is skipped by NextPart
+			continue
+		}
+		if err != nil {
+			return true, err
+		}
+		ev, err := parseAlert(data)
+		if err != nil {
+			// skip parts we can't decode, e.g. attached pictures
+			continue
+		}
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+}
+
+// openAlertStream connects to the stream. A 401 is answered once right away by
+// authenticating again, e.g. the first Digest challenge or an expired session.
+func (hik *HikISAPI) openAlertStream(ctx context.Context) (*http.Response, error) {
+	for retried := false; ; retried = true {
+		req, err := http.NewRequestWithContext(ctx, "GET", hik.endpoint(AlertStream), nil)
+		if err != nil {
+			return nil, err
+		}
+		_, digest, gen := hik.sess.state()
+		hik.authorize(req)
+		resp, err := hik.stream.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != 401 || retried {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if digest != nil {
+			if err := digest.challenge(resp.Header); err != nil {
+				return nil, err
+			}
+		} else if err := hik.renew(ctx, gen); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseAlert decodes a JSON or XML event notification into a typed event
+func parseAlert(data []byte) (AlertEvent, error) {
+	n := EventNotification{}
+	data = bytes.TrimSpace(data)
+	var err error
+	if len(data) > 0 && data[0] == '<' {
+		err = xml.Unmarshal(data, &n)
+	} else {
+		err = json.Unmarshal(data, &n)
+	}
+	if err != nil {
+		return AlertEvent{}, err
+	}
+
+	ev := AlertEvent{
+		Kind:        EventOther,
+		Description: n.EventDescription,
+		Raw:         n,
+	}
+	ev.Time, err = time.Parse(time.RFC3339, n.DateTime)
+	if err != nil {
+		ev.Time = time.Now()
+	}
+
+	eventType := strings.ToLower(n.EventType)
+	if eventType == "heartbeat" || (eventType == "videoloss" && n.EventState == "inactive") {
+		ev.Kind = EventHeartbeat
+		return ev, nil
+	}
+	if n.CIDEvent == nil {
+		return ev, nil
+	}
+
+	cid := n.CIDEvent
+	ev.Code = cid.StandardCIDcode
+	if ev.Code == 0 {
+		ev.Code = cid.Code
+	}
+	ev.Zone = cid.Zone
+	ev.ZoneName = cid.ZoneName
+	ev.SubSystem = cid.System
+	ev.User = cid.UserName
+
+	// Contact ID: the first digit is the qualifier (1 new event/opening, 3 restore/closing),
+	// the last three digits are the event code
+	qualifier, code := ev.Code/1000, ev.Code%1000
+	ev.Restore = qualifier == 3
+	switch {
+	case code == 137 || code == 144 || code == 145 || code == 383:
+		ev.Kind = EventTamper
+	case code >= 100 && code < 200:
+		ev.Kind = EventZoneAlarm
+	case code >= 300 && code < 400:
+		ev.Kind = EventFault
+	case code >= 400 && code < 500:
+		// arming is reported as a closing, disarming as an opening
+		ev.Restore = false
+		if qualifier == 3 {
+			ev.Kind = EventArm
+		} else {
+			ev.Kind = EventDisarm
+		}
+	}
+	return ev, nil
+}
@@ -0,0 +1,83 @@
+package hikaxprogo
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAlertStreamAnswersDigestChallenge(t *testing.T) {
+	p := &fakePanel{handle: func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Authorization") == "" {
+			resp := reply(401, "")
+			resp.Header.Add("WWW-Authenticate", `Digest realm="panel", qop="auth", nonce="n1"`)
+			return resp, nil
+		}
+		resp := reply(200, "--b\r\nContent-Type: application/json\r\n\r\n"+
+			`{"eventType":"cidEvent","CIDEvent":{"code":1130,"zone":3}}`+"\r\n--b--\r\n")
+		resp.Header.Set("Content-Type", "multipart/mixed; boundary=b")
+		return resp, nil
+	}}
+	hik := newTestPanel(p, fastRetry, WithAuth(AuthDigest))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	select {
+	case ev := <-hik.AlertStream(ctx):
+		if ev.Kind != EventZoneAlarm || ev.Zone != 3 {
+			t.Errorf("got %s event %+v, want the zone alarm without a stream error first", ev.Kind, ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	if n := p.count("GET " + AlertStream); n != 2 {
+		t.Errorf("connected %d times, want 2", n)
+	}
+}
+
+func TestParseAlert(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		kind    string
+		restore bool
+	}{
+		{"heartbeat", `{"eventType":"heartBeat"}`, EventHeartbeat, false},
+		{"videoloss heartbeat", `<EventNotificationAlert><eventType>videoloss</eventType><eventState>inactive</eventState></EventNotificationAlert>`, EventHeartbeat, false},
+		{"without CID", `{"eventType":"unknown"}`, EventOther, false},
+		{"burglary", `{"eventType":"cidEvent","CIDEvent":{"code":1130,"zone":3,"system":1}}`, EventZoneAlarm, false},
+		{"burglary restore", `<EventNotificationAlert><eventType>cidEvent</eventType><CIDEvent><code>3130</code><zone>3</zone></CIDEvent></EventNotificationAlert>`, EventZoneAlarm, true},
+		{"standard code wins", `{"eventType":"cidEvent","CIDEvent":{"code":9999,"standardCIDcode":1301}}`, EventFault, false},
+		{"sensor tamper", `{"eventType":"cidEvent","CIDEvent":{"code":1144}}`, EventTamper, false},
+		{"case tamper restore", `{"eventType":"cidEvent","CIDEvent":{"code":3137}}`, EventTamper, true},
+		{"module tamper", `{"eventType":"cidEvent","CIDEvent":{"code":1383}}`, EventTamper, false},
+		{"AC loss restore", `<EventNotificationAlert><eventType>cidEvent</eventType><CIDEvent><code>3301</code></CIDEvent></EventNotificationAlert>`, EventFault, true},
+		{"arm", `{"eventType":"cidEvent","CIDEvent":{"code":3401,"userName":"admin"}}`, EventArm, false},
+		{"disarm", `<EventNotificationAlert><eventType>cidEvent</eventType><CIDEvent><code>1401</code></CIDEvent></EventNotificationAlert>`, EventDisarm, false},
+		{"other code", `{"eventType":"cidEvent","CIDEvent":{"code":1602}}`, EventOther, false},
+	}
+	for _, tt := range tests {
+		ev, err := parseAlert([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if ev.Kind != tt.kind || ev.Restore != tt.restore {
+			t.Errorf("%s: got %s restore %v, want %s restore %v", tt.name, ev.Kind, ev.Restore, tt.kind, tt.restore)
+		}
+	}
+
+	ev, err := parseAlert([]byte(`<EventNotificationAlert><dateTime>2024-05-01T10:00:00+02:00</dateTime><eventType>cidEvent</eventType>` +
+		`<CIDEvent><code>1130</code><zone>3</zone><zoneName>door</zoneName><system>2</system><userName>admin</userName></CIDEvent></EventNotificationAlert>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Code != 1130 || ev.Zone != 3 || ev.ZoneName != "door" || ev.SubSystem != 2 || ev.User != "admin" ||
+		!ev.Time.Equal(time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("fields not mapped: %+v", ev)
+	}
+	if _, err := parseAlert([]byte("\xff\xd8 picture")); err == nil {
+		t.Error("binary part decoded")
+	}
+}
@@ -0,0 +1,371 @@
+package hikaxprogo
+
+import (
+	"bytes"
+	"context"
+	json "encoding/json"
+	xml "encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotSupported is returned when the panel lacks a feature
+var ErrNotSupported = errors.New("not supported by the panel")
+
+// Feature flags as reported in the capability documents
+const (
+	FeatureSubSystemStatus = "isSupportSubSystemStatus"
+	FeatureHostStatus      = "isSupportHostStatus"
+	FeatureBypass          = "isSupportBypass"
+	FeatureOutputsCtrl     = "isSupportOutputsCtrl"
+	FeatureCheckResult     = "isSupportCheckResult"
+)
+
+// Capabilities holds the capability documents the panel reported at login
+type Capabilities struct {
+	Loaded bool
+	// Flags are all boolean isSupport... style flags of all documents by element name
+	Flags map[string]bool
+	// Raw documents by endpoint, nil when the panel doesn't provide the document
+	SecurityCP     []byte
+	Status         []byte
+	Configuration  []byte
+	Zones          []byte
+	DetectorConfig []byte
+}
+
+// Supports reports whether the panel supports the feature. Features the panel
+// doesn't mention are assumed to be supported, only explicit "false" flags count.
+func (c Capabilities) Supports(feature string) bool {
+	supported, ok := c.Flags[feature]
+	return !ok || supported
+}
+
+// Capabilities returns the capabilities probed at login, empty before the first complete probe
+func (hik *HikISAPI) Capabilities() Capabilities {
+	if caps := hik.caps.Load(); caps != nil {
+		return *caps
+	}
+	return Capabilities{}
+}
+
+// ProbeCapabilities fetches and parses the capability documents of the panel.
+// Documents the firmware doesn't provide are skipped, the capabilities are only
+// replaced when every other document was fetched.
+func (hik *HikISAPI) ProbeCapabilities(ctx context.Context) error {
+	return hik.probeCapabilities(ctx)
+}
+
+// Capability probes run detached from the caller, a failed one is repeated after probeRetry
+const (
+	probeTimeout = 2 * time.Minute
+	probeRetry   = 30 * time.Second
+)
+
+// probeCapabilities builds the capabilities and publishes them when complete. A document
+// that failed for another reason than missing support, e.g. a network error, leaves the
+// capabilities as they are, so the next caller probes again.
+func (hik *HikISAPI) probeCapabilities(ctx context.Context) error {
+	caps := Capabilities{Loaded: true, Flags: map[string]bool{}}
+	docs := []struct {
+		path string
+		dst  *[]byte
+	}{
+		{Caps, &caps.SecurityCP},
+		{StatusCap, &caps.Status},
+		{ConfCap, &caps.Configuration},
+		{ZonesCap, &caps.Zones},
+		{DetectorConfigCap, &caps.DetectorConfig},
+	}
+	var errs []error
+	complete := true
+	for _, doc := range docs {
+		data, err := hik.getRaw(ctx, doc.path)
+		if err != nil {
+			if !errors.Is(err, ErrNotSupported) {
+				errs = append(errs, err)
+				complete = false
+			}
+			continue
+		}
+		*doc.dst = data
+		if err := collectFlags(data, caps.Flags); err != nil {
+			errs = append(errs, fmt.Errorf("parse %s: %w", doc.path, err))
+		}
+	}
+	if complete {
+		hik.caps.Store(&caps)
+	}
+	return errors.Join(errs...)
+}
+
+// loadCapabilities returns the capabilities, probing them on first use. Concurrent
+// callers share one probe, which outlives the context of the caller. A caller whose
+// context ends first gets empty capabilities, all features are assumed to be supported then.
+func (hik *HikISAPI) loadCapabilities(ctx context.Context) Capabilities {
+	if caps := hik.caps.Load(); caps != nil {
+		return *caps
+	}
+	hik.probe.Lock()
+	done := hik.probing
+	if done == nil {
+		if time.Now().Before(hik.nextProbe) {
+			hik.probe.Unlock()
+			return Capabilities{}
+		}
+		done = make(chan struct{})
+		hik.probing = done
+		go hik.runProbe(context.WithoutCancel(ctx), done)
+	}
+	hik.probe.Unlock()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return hik.Capabilities()
+}
+
+// runProbe probes with its own timeout and closes done when finished
+func (hik *HikISAPI) runProbe(ctx context.Context, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	_ = hik.probeCapabilities(ctx)
+
+	hik.probe.Lock()
+	if hik.caps.Load() == nil {
+		hik.nextProbe = time.Now().Add(probeRetry)
+	}
+	hik.probing = nil
+	hik.probe.Unlock()
+	close(done)
+}
+
+// requireFeature returns ErrNotSupported when the probed capabilities rule the feature out
+func (hik *HikISAPI) requireFeature(ctx context.Context, feature string) error {
+	if !hik.loadCapabilities(ctx).Supports(feature) {
+		return fmt.Errorf("%s: %w", feature, ErrNotSupported)
+	}
+	return nil
+}
+
+// collectFlags walks a JSON or XML capability document and stores every boolean leaf by name
+func collectFlags(data []byte, flags map[string]bool) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '<' {
+		return collectXMLFlags(data, flags)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	collectJSONFlags(doc, flags)
+	return nil
+}
+
+func collectJSONFlags(v interface{}, flags map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			switch value := value.(type) {
+			case bool:
+				flags[key] = value
+			case string:
+				if b, ok := parseFlag(value); ok {
+					flags[key] = b
+				}
+			default:
+				collectJSONFlags(value, flags)
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			collectJSONFlags(value, flags)
+		}
+	}
+}
+
+func collectXMLFlags(data []byte, flags map[string]bool) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var name string
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			name = tok.Name.Local
+			text.Reset()
+		case xml.CharData:
+			text.Write(tok)
+		case xml.EndElement:
+			if tok.Name.Local == name {
+				if b, ok := parseFlag(text.String()); ok {
+					flags[name] = b
+				}
+			}
+			name = ""
+			text.Reset()
+		}
+	}
+}
+
+func parseFlag(s string) (value bool, ok bool) {
+	switch strings.TrimSpace(s) {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
+
+// Constraint is the allowed range or set of options of a configuration field
+type Constraint struct {
+	Min *int
+	Max *int
+	Opt []string
+}
+
+// ErrInvalidConfig is returned when a configuration is rejected by the capabilities before sending
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Constraints returns the field constraints of a capability document by field name,
+// as "@min", "@max" and "@opt" members in JSON or min, max and opt attributes in XML
+func Constraints(doc []byte) (map[string]Constraint, error) {
+	constraints := map[string]Constraint{}
+	doc = bytes.TrimSpace(doc)
+	if len(doc) == 0 {
+		return constraints, nil
+	}
+	if doc[0] == '<' {
+		dec := xml.NewDecoder(bytes.NewReader(doc))
+		for {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				return constraints, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if el, ok := tok.(xml.StartElement); ok {
+				attrs := map[string]string{}
+				for _, a := range el.Attr {
+					attrs[a.Name.Local] = a.Value
+				}
+				if c, ok := newConstraint(attrs["min"], attrs["max"], attrs["opt"]); ok {
+					constraints[el.Name.Local] = c
+				}
+			}
+		}
+	}
+	var v interface{}
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return nil, err
+	}
+	collectJSONConstraints(v, constraints)
+	return constraints, nil
+}
+
+func collectJSONConstraints(v interface{}, constraints map[string]Constraint) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if m, ok := value.(map[string]interface{}); ok {
+				if c, ok := newConstraint(fmt.Sprint(m["@min"]), fmt.Sprint(m["@max"]), fmt.Sprint(m["@opt"])); ok {
+					constraints[key] = c
+				}
+			}
+			collectJSONConstraints(value, constraints)
+		}
+	case []interface{}:
+		for _, value := range v {
+			collectJSONConstraints(value, constraints)
+		}
+	}
+}
+
+func newConstraint(min string, max string, opt string) (Constraint, bool) {
+	c := Constraint{}
+	if n, err := strconv.Atoi(min); err == nil {
+		c.Min = &n
+	}
+	if n, err := strconv.Atoi(max); err == nil {
+		c.Max = &n
+	}
+	if opt != "" && opt != "<nil>" {
+		c.Opt = strings.Split(opt, ",")
+	}
+	return c, c.Min != nil || c.Max != nil || c.Opt != nil
+}
+
+// check validates a single JSON value, strings are checked for options and length, numbers for range
+func (c Constraint) check(value interface{}) error {
+	switch value := value.(type) {
+	case string:
+		if len(c.Opt) > 0 && !slices.Contains(c.Opt, value) {
+			return fmt.Errorf("%q is not one of %s", value, strings.Join(c.Opt, ","))
+		}
+		return c.checkRange(len(value), "length")
+	case float64:
+		return c.checkRange(int(value), "value")
+	case []interface{}:
+		for _, item := range value {
+			if err := c.check(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c Constraint) checkRange(n int, what string) error {
+	if c.Min != nil && n < *c.Min {
+		return fmt.Errorf("%s %d is below %d", what, n, *c.Min)
+	}
+	if c.Max != nil && n > *c.Max {
+		return fmt.Errorf("%s %d is above %d", what, n, *c.Max)
+	}
+	return nil
+}
+
+// validateConfig validates cfg against a capability document, probing the capabilities first if needed
+func (hik *HikISAPI) validateConfig(ctx context.Context, doc func(c Capabilities) []byte, cfg interface{}) error {
+	// an incomplete probe only means less validation
+	return validate(doc(hik.loadCapabilities(ctx)), cfg)
+}
+
+// validate checks every field of cfg against the constraints of the capability document,
+// fields without constraints and missing documents pass
+func validate(doc []byte, cfg interface{}) error {
+	constraints, err := Constraints(doc)
+	if err != nil || len(constraints) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var errs []error
+	for name, value := range fields {
+		if c, ok := constraints[name]; ok {
+			if err := c.check(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v: %w", name, err, ErrInvalidConfig))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
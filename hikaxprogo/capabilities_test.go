@@ -0,0 +1,128 @@
+package hikaxprogo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is a fake transport answering requests without a panel
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func reply(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestCapabilitiesConcurrentProbe(t *testing.T) {
+	var mu sync.Mutex
+	probes := 0
+	rt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case Caps:
+			mu.Lock()
+			probes++
+			mu.Unlock()
+			return reply(200, `{"SecurityCPCap":{"isSupportSubSystemStatus":false,"isSupportHostStatus":true}}`), nil
+		case "/ISAPI/SecurityCP/status/zones":
+			return reply(200, `{"ZoneList":[]}`), nil
+		}
+		return reply(404, ""), nil
+	})
+	hik := New("panel", "", "admin", "secret", WithTransport(rt), WithAuth(AuthDigest))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = hik.SubSystemStatus(context.Background())
+			_, _ = hik.ZoneStatus(context.Background())
+			_, _ = hik.HostStatus(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	if probes != 1 {
+		t.Errorf("probed %d times, want 1", probes)
+	}
+	caps := hik.Capabilities()
+	if caps.Supports(FeatureSubSystemStatus) || !caps.Supports(FeatureHostStatus) {
+		t.Errorf("unexpected flags %v", caps.Flags)
+	}
+}
+
+func TestCapabilitiesProbeAgainAfterFailure(t *testing.T) {
+	var mu sync.Mutex
+	down := true
+	rt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			return nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+		}
+		if req.URL.Path == Caps {
+			return reply(200, `{"SecurityCPCap":{"isSupportBypass":false}}`), nil
+		}
+		return reply(404, ""), nil
+	})
+	hik := New("panel", "", "admin", "secret", WithTransport(rt), WithAuth(AuthDigest),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+	if caps := hik.loadCapabilities(context.Background()); caps.Loaded {
+		t.Fatal("capabilities stored after a failed probe")
+	}
+	if err := hik.requireFeature(context.Background(), FeatureBypass); err != nil {
+		t.Errorf("feature refused without capabilities: %v", err)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	hik.probe.Lock()
+	hik.nextProbe = time.Time{}
+	hik.probe.Unlock()
+	if err := hik.requireFeature(context.Background(), FeatureBypass); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v after the panel came back, want ErrNotSupported", err)
+	}
+}
+
+func TestCapabilitiesProbeOutlivesCaller(t *testing.T) {
+	release := make(chan struct{})
+	rt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		<-release
+		return reply(200, `{"SecurityCPCap":{"isSupportBypass":false}}`), nil
+	})
+	hik := New("panel", "", "admin", "secret", WithTransport(rt), WithAuth(AuthDigest))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if caps := hik.loadCapabilities(ctx); caps.Loaded {
+		t.Fatal("capabilities loaded before the panel answered")
+	}
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for !hik.Capabilities().Loaded {
+		if time.Now().After(deadline) {
+			t.Fatal("probe didn't complete after the caller gave up")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if hik.Capabilities().Supports(FeatureBypass) {
+		t.Error("bypass flag not probed")
+	}
+}
@@ -0,0 +1,104 @@
+package hikaxprogo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+type CheckResultData struct {
+	CheckResult ArmCheck `json:"CheckResult"`
+}
+
+// ArmCheck is the pre-arm check of the panel: the faults and open zones that block arming
+type ArmCheck struct {
+	FaultList    []FaultList    `json:"FaultList"`
+	OpenZoneList []OpenZoneList `json:"OpenZoneList"`
+}
+
+type FaultList struct {
+	Fault CheckFault `json:"Fault"`
+}
+
+// CheckFault is an active fault, e.g. AC loss, low battery, tamper or a detector offline
+type CheckFault struct {
+	Type          string `json:"type"`
+	Description   string `json:"description"`
+	ID            int    `json:"id"` // zone or peripheral the fault relates to
+	Name          string `json:"name"`
+	SubSystemList []int  `json:"subSystemList"`
+}
+
+type OpenZoneList struct {
+	Zone OpenZone `json:"Zone"`
+}
+
+// OpenZone is a zone that is triggered (e.g. a window is open) while arming
+type OpenZone struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	SubSystemNo int    `json:"subSystemNo"`
+}
+
+// Passed reports whether nothing blocks arming
+func (c ArmCheck) Passed() bool {
+	return len(c.FaultList) == 0 && len(c.OpenZoneList) == 0
+}
+
+// Reasons returns a readable description of everything that blocks arming
+func (c ArmCheck) Reasons() []string {
+	var reasons []string
+	for _, z := range c.OpenZoneList {
+		reasons = append(reasons, fmt.Sprintf("zone %d (%s) is open", z.Zone.ID, z.Zone.Name))
+	}
+	for _, f := range c.FaultList {
+		reason := f.Fault.Type
+		if f.Fault.Description != "" {
+			reason = f.Fault.Description
+		}
+		if f.Fault.Name != "" {
+			reason += fmt.Sprintf(" (%s)", f.Fault.Name)
+		}
+		reasons = append(reasons, "fault: "+reason)
+	}
+	return reasons
+}
+
+// ForAreas returns only the faults and open zones that affect the given areas,
+// faults not linked to any area concern the whole panel and are kept
+func (c ArmCheck) ForAreas(ids ...int) ArmCheck {
+	if len(ids) == 0 {
+		return c
+	}
+	res := ArmCheck{}
+	for _, z := range c.OpenZoneList {
+		if slices.Contains(ids, z.Zone.SubSystemNo) {
+			res.OpenZoneList = append(res.OpenZoneList, z)
+		}
+	}
+	for _, f := range c.FaultList {
+		keep := len(f.Fault.SubSystemList) == 0
+		for _, id := range ids {
+			keep = keep || slices.Contains(f.Fault.SubSystemList, id)
+		}
+		if keep {
+			res.FaultList = append(res.FaultList, f)
+		}
+	}
+	return res
+}
+
+// PreArmCheck returns the faults and open zones that block arming of the given areas,
+// or of the whole panel when no area is given
+func (hik *HikISAPI) PreArmCheck(ctx context.Context, areas ...int) (ArmCheck, error) {
+	c := CheckResultData{}
+	if err := hik.requireFeature(ctx, FeatureCheckResult); err != nil {
+		return c.CheckResult, err
+	}
+	err := hik.getJSON(ctx, CheckResult, &c)
+	if err != nil {
+		return c.CheckResult, err
+	}
+	return c.CheckResult.ForAreas(areas...), nil
+}
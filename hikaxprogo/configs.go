@@ -3,9 +3,13 @@ package hikaxprogo
 const (
 	Session_Capabilities = "/ISAPI/Security/sessionLogin/capabilities?username="
 	Session_Login        = "/ISAPI/Security/sessionLogin"
+	Session_Heartbeat    = "/ISAPI/Security/sessionHeartbeat"
+	Session_Logout       = "/ISAPI/Security/sessionLogout"
 	Alarm_Disarm         = "/ISAPI/SecurityCP/control/disarm/0xffffffff"
 	Alarm_ArmAway        = "/ISAPI/SecurityCP/control/arm/0xffffffff?ways=away"
 	Alarm_ArmHome        = "/ISAPI/SecurityCP/control/arm/0xffffffff?ways=stay"
+	Alarm_DisarmArea     = "/ISAPI/SecurityCP/control/disarm/"
+	Alarm_ArmArea        = "/ISAPI/SecurityCP/control/arm/"
 	SubSystemStatus      = "/ISAPI/SecurityCP/status/subSystems"
 	AlertStream          = "/ISAPI/Event/notification/alertStream"
 	DetectorConfig       = "/ISAPI/SecurityCP/BasicParam/DetectorCfg"
@@ -18,8 +22,8 @@ const (
 	EventRecordCap       = "/ISAPI/SecurityCP/Configuration/eventRecord/channels/2/capabilities"
 	EventRecord          = "/ISAPI/SecurityCP/Configuration/eventRecord/channels/1"
 	FaultCheck           = "/ISAPI/SecurityCP/Configuration/faultCheckCfg"
-	GlassBreakDetector   = "/ISAPI/SecurityCP/Configuration/glassBreakDetector/zone/5"
-	MagneticContact      = "/ISAPI/SecurityCP/Configuration/magneticContact/zone/0"
+	GlassBreakDetector   = "/ISAPI/SecurityCP/Configuration/glassBreakDetector/zone/"
+	MagneticContact      = "/ISAPI/SecurityCP/Configuration/magneticContact/zone/"
 	PublicSubSystem      = "/ISAPI/SecurityCP/Configuration/publicSubSys"
 	ZonesCap             = "/ISAPI/SecurityCP/Configuration/zones/capabilities"
 	Zones                = "/ISAPI/SecurityCP/Configuration/zones/"
@@ -31,5 +35,7 @@ const (
 	BypassZone           = "/ISAPI/SecurityCP/control/bypass/"
 	RecoverBypassZone    = "/ISAPI/SecurityCP/control/Recoverbypass/"
 	InterfaceInfo        = "/ISAPI/System/Network/interfaces"
+	OutputControl        = "/ISAPI/SecurityCP/control/outputs/"
+	LogSearch            = "/ISAPI/ContentMgmt/logSearch"
 	AreaArmStatus        = "/ISAPI/SecurityCP/status/armStatus"
 )
@@ -0,0 +1,230 @@
+package hikaxprogo
+
+import (
+	"bytes"
+	"context"
+	json "encoding/json"
+	xml "encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ResponseStatus is the generic ISAPI reply to control and configuration requests
+type ResponseStatus struct {
+	RequestURL    string `json:"requestURL" xml:"requestURL"`
+	StatusCode    int    `json:"statusCode" xml:"statusCode"`
+	StatusString  string `json:"statusString" xml:"statusString"`
+	SubStatusCode string `json:"subStatusCode" xml:"subStatusCode"`
+	ErrorCode     int    `json:"errorCode" xml:"errorCode"`
+	ErrorMsg      string `json:"errorMsg" xml:"errorMsg"`
+}
+
+// ResponseError is returned when the panel refuses a request,
+// e.g. arming with open zones, active faults or insufficient permissions
+type ResponseError struct {
+	HTTPStatus int
+	Status     ResponseStatus
+}
+
+func (e *ResponseError) Error() string {
+	msg := e.Status.SubStatusCode
+	if e.Status.ErrorMsg != "" {
+		msg = e.Status.ErrorMsg
+	}
+	return fmt.Sprintf("request %s refused: %s (status %d, http %d)",
+		e.Status.RequestURL, msg, e.Status.StatusCode, e.HTTPStatus)
+}
+
+// Common refusals, ResponseError matches them with errors.Is
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrDeviceBusy         = errors.New("device busy")
+	ErrInvalidParameter   = errors.New("invalid parameter")
+)
+
+// ISAPI status codes of ResponseStatus
+const (
+	StatusOK             = 1
+	StatusDeviceBusy     = 2
+	StatusDeviceError    = 3
+	StatusInvalidOp      = 4
+	StatusInvalidFormat  = 5
+	StatusInvalidContent = 6
+	StatusRebootRequired = 7
+)
+
+// Is matches the refusal against ErrNotSupported, ErrInvalidCredentials,
+// ErrDeviceBusy and ErrInvalidParameter
+func (e *ResponseError) Is(target error) bool {
+	sub := e.Status.SubStatusCode
+	switch target {
+	case ErrNotSupported:
+		return e.HTTPStatus == 404 || sub == "notSupport"
+	case ErrInvalidCredentials:
+		return e.HTTPStatus == 401 || sub == "badAuthorization" || sub == "userPwdError"
+	case ErrDeviceBusy:
+		return e.HTTPStatus == 503 || e.Status.StatusCode == StatusDeviceBusy || sub == "deviceBusy"
+	case ErrInvalidParameter:
+		return e.Status.StatusCode == StatusInvalidFormat || e.Status.StatusCode == StatusInvalidContent ||
+			sub == "badParameters" || sub == "invalidParameter"
+	}
+	return false
+}
+
+// responseError decodes the ResponseStatus of a refused request, the body may be empty or anything else
+func responseError(httpStatus int, path string, data []byte) *ResponseError {
+	status, _ := parseResponseStatus(data)
+	if status.RequestURL == "" {
+		status.RequestURL = path
+	}
+	return &ResponseError{HTTPStatus: httpStatus, Status: status}
+}
+
+// Disarm disarms all areas of the panel
+func (hik *HikISAPI) Disarm(ctx context.Context) error {
+	return hik.control(ctx, "PUT", Alarm_Disarm, "")
+}
+
+// ArmAway arms all areas of the panel in away mode
+func (hik *HikISAPI) ArmAway(ctx context.Context) error {
+	return hik.control(ctx, "PUT", Alarm_ArmAway, "")
+}
+
+// ArmStay arms all areas of the panel in stay (home) mode
+func (hik *HikISAPI) ArmStay(ctx context.Context) error {
+	return hik.control(ctx, "PUT", Alarm_ArmHome, "")
+}
+
+// DisarmAreas disarms the given areas (subsystems)
+func (hik *HikISAPI) DisarmAreas(ctx context.Context, ids ...int) error {
+	return hik.controlEach(ctx, "area", 1, ids, func(id int) string {
+		return Alarm_DisarmArea + strconv.Itoa(id)
+	})
+}
+
+// ArmAwayAreas arms the given areas (subsystems) in away mode
+func (hik *HikISAPI) ArmAwayAreas(ctx context.Context, ids ...int) error {
+	return hik.controlEach(ctx, "area", 1, ids, func(id int) string {
+		return Alarm_ArmArea + strconv.Itoa(id) + "?ways=away"
+	})
+}
+
+// ArmStayAreas arms the given areas (subsystems) in stay (home) mode
+func (hik *HikISAPI) ArmStayAreas(ctx context.Context, ids ...int) error {
+	return hik.controlEach(ctx, "area", 1, ids, func(id int) string {
+		return Alarm_ArmArea + strconv.Itoa(id) + "?ways=stay"
+	})
+}
+
+// Areas returns the sorted list of area (subsystem) numbers used by zones and peripherals
+func (hik *HikISAPI) Areas(ctx context.Context) ([]int, error) {
+	zones, err := hik.ZoneStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	exDev, err := hik.ExDevData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mergeAreas(zones.SubSystems(), exDev.ExDevStatus.SubSystems()), nil
+}
+
+// BypassZones bypasses the given zones so that they are ignored while armed
+func (hik *HikISAPI) BypassZones(ctx context.Context, ids ...int) error {
+	if err := hik.requireFeature(ctx, FeatureBypass); err != nil {
+		return err
+	}
+	return hik.controlEach(ctx, "zone", 0, ids, func(id int) string {
+		return BypassZone + strconv.Itoa(id)
+	})
+}
+
+// RecoverBypass returns the given bypassed zones to normal operation
+func (hik *HikISAPI) RecoverBypass(ctx context.Context, ids ...int) error {
+	if err := hik.requireFeature(ctx, FeatureBypass); err != nil {
+		return err
+	}
+	return hik.controlEach(ctx, "zone", 0, ids, func(id int) string {
+		return RecoverBypassZone + strconv.Itoa(id)
+	})
+}
+
+// controlEach sends one command per area or zone, the panel addresses them by number in the URL
+func (hik *HikISAPI) controlEach(ctx context.Context, kind string, minID int, ids []int, path func(id int) string) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("no %ss given", kind)
+	}
+	var errs []error
+	for _, id := range mergeAreas(ids) {
+		if id < minID {
+			errs = append(errs, fmt.Errorf("%s %d: invalid %s number", kind, id, kind))
+			continue
+		}
+		if err := hik.control(ctx, "PUT", path(id), ""); err != nil {
+			errs = append(errs, fmt.Errorf("%s %d: %w", kind, id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// mergeAreas returns the sorted distinct area (or zone) numbers from all lists
+func mergeAreas(lists ...[]int) []int {
+	seen := map[int]bool{}
+	var res []int
+	for _, list := range lists {
+		for _, id := range list {
+			if !seen[id] {
+				seen[id] = true
+				res = append(res, id)
+			}
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+// putJSON sends v as JSON to the panel and checks the ResponseStatus reply
+func (hik *HikISAPI) putJSON(ctx context.Context, path string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return hik.control(ctx, "PUT", path, string(body))
+}
+
+// control sends a command to the panel and checks the ResponseStatus reply
+func (hik *HikISAPI) control(ctx context.Context, method string, path string, body string) error {
+	resp, err := hik.makeRequest(ctx, method, hik.endpoint(path), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := readResponse(resp, path)
+	if err != nil {
+		return err
+	}
+	status, err := parseResponseStatus(data)
+	if err != nil {
+		return fmt.Errorf("parse response of %s: %w", path, err)
+	}
+	if status.StatusCode != StatusOK {
+		return responseError(resp.StatusCode, path, data)
+	}
+	return nil
+}
+
+// parseResponseStatus decodes a ResponseStatus reply, which the panel sends
+// either as XML or as JSON depending on firmware and request format
+func parseResponseStatus(data []byte) (ResponseStatus, error) {
+	status := ResponseStatus{}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '<' {
+		err := xml.Unmarshal(data, &status)
+		return status, err
+	}
+	err := json.Unmarshal(data, &status)
+	return status, err
+}
@@ -0,0 +1,34 @@
+package hikaxprogo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResponseErrorIs(t *testing.T) {
+	sentinels := []error{ErrNotSupported, ErrInvalidCredentials, ErrDeviceBusy, ErrInvalidParameter}
+	tests := []struct {
+		name       string
+		httpStatus int
+		body       string
+		want       error // nil matches none of the sentinels
+	}{
+		{"404", 404, "", ErrNotSupported},
+		{"notSupport", 200, `{"statusCode":4,"subStatusCode":"notSupport"}`, ErrNotSupported},
+		{"401", 401, "", ErrInvalidCredentials},
+		{"userPwdError", 200, `<ResponseStatus><statusCode>4</statusCode><subStatusCode>userPwdError</subStatusCode></ResponseStatus>`, ErrInvalidCredentials},
+		{"503", 503, "", ErrDeviceBusy},
+		{"deviceBusy", 200, `{"statusCode":2,"subStatusCode":"deviceBusy"}`, ErrDeviceBusy},
+		{"badParameters", 400, `{"statusCode":4,"subStatusCode":"badParameters"}`, ErrInvalidParameter},
+		{"invalidContent", 400, `{"statusCode":6,"subStatusCode":"badXmlContent"}`, ErrInvalidParameter},
+		{"other refusal", 403, `{"statusCode":4,"subStatusCode":"lowPrivilege"}`, nil},
+	}
+	for _, tt := range tests {
+		err := responseError(tt.httpStatus, "/ISAPI/test", []byte(tt.body))
+		for _, sentinel := range sentinels {
+			if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+				t.Errorf("%s: errors.Is(%v) = %v", tt.name, sentinel, got)
+			}
+		}
+	}
+}
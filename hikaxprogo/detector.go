@@ -0,0 +1,87 @@
+package hikaxprogo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+type DetectorCfgData struct {
+	DetectorCfg DetectorCfg `json:"DetectorCfg"`
+}
+
+// DetectorCfg holds the basic parameters of the detector of a zone
+type DetectorCfg struct {
+	ZoneID      int  `json:"zoneID"`
+	Sensitivity int  `json:"sensitivity"`
+	LEDEnabled  bool `json:"LEDEnabled"` // indicator LED lights up on detection
+	PulseCount  int  `json:"pulseCount"` // detections needed to raise an alarm
+}
+
+type GlassBreakDetectorData struct {
+	GlassBreakDetector GlassBreakDetectorCfg `json:"GlassBreakDetector"`
+}
+
+// GlassBreakDetectorCfg holds the parameters of a glass-break detector
+type GlassBreakDetectorCfg struct {
+	Sensitivity string `json:"sensitivity"` // "low", "medium" or "high"
+	LEDEnabled  bool   `json:"LEDEnabled"`
+}
+
+type MagneticContactData struct {
+	MagneticContact MagneticContactCfg `json:"MagneticContact"`
+}
+
+// MagneticContactCfg holds the options of a magnetic door/window contact
+type MagneticContactCfg struct {
+	MagneticEnabled      bool   `json:"magneticEnabled"`
+	ExternalInputEnabled bool   `json:"externalInputEnabled"`
+	ExternalInputType    string `json:"externalInputType"` // "NO" or "NC"
+	ShockSensitivity     string `json:"shockSensitivity"`
+	TiltEnabled          bool   `json:"tiltEnabled"`
+	LEDEnabled           bool   `json:"LEDEnabled"`
+}
+
+// GetDetectorConfig returns the detector parameters of the zone
+func (hik *HikISAPI) GetDetectorConfig(ctx context.Context, zone int) (DetectorCfg, error) {
+	d := DetectorCfgData{}
+	err := hik.getJSON(ctx, detectorConfigPath(zone), &d)
+	return d.DetectorCfg, err
+}
+
+// SetDetectorConfig updates the detector parameters of the zone cfg.ZoneID,
+// the parameters are validated against the detector capabilities before sending
+func (hik *HikISAPI) SetDetectorConfig(ctx context.Context, cfg DetectorCfg) error {
+	if err := hik.validateConfig(ctx, func(c Capabilities) []byte { return c.DetectorConfig }, cfg); err != nil {
+		return fmt.Errorf("zone %d: %w", cfg.ZoneID, err)
+	}
+	return hik.putJSON(ctx, detectorConfigPath(cfg.ZoneID), DetectorCfgData{DetectorCfg: cfg})
+}
+
+// GetGlassBreakConfig returns the parameters of the glass-break detector of the zone
+func (hik *HikISAPI) GetGlassBreakConfig(ctx context.Context, zone int) (GlassBreakDetectorCfg, error) {
+	d := GlassBreakDetectorData{}
+	err := hik.getJSON(ctx, GlassBreakDetector+strconv.Itoa(zone), &d)
+	return d.GlassBreakDetector, err
+}
+
+// SetGlassBreakConfig updates the parameters of the glass-break detector of the zone
+func (hik *HikISAPI) SetGlassBreakConfig(ctx context.Context, zone int, cfg GlassBreakDetectorCfg) error {
+	return hik.putJSON(ctx, GlassBreakDetector+strconv.Itoa(zone), GlassBreakDetectorData{GlassBreakDetector: cfg})
+}
+
+// GetMagneticContactConfig returns the options of the magnetic contact of the zone
+func (hik *HikISAPI) GetMagneticContactConfig(ctx context.Context, zone int) (MagneticContactCfg, error) {
+	d := MagneticContactData{}
+	err := hik.getJSON(ctx, MagneticContact+strconv.Itoa(zone), &d)
+	return d.MagneticContact, err
+}
+
+// SetMagneticContactConfig updates the options of the magnetic contact of the zone
+func (hik *HikISAPI) SetMagneticContactConfig(ctx context.Context, zone int, cfg MagneticContactCfg) error {
+	return hik.putJSON(ctx, MagneticContact+strconv.Itoa(zone), MagneticContactData{MagneticContact: cfg})
+}
+
+func detectorConfigPath(zone int) string {
+	return DetectorConfig + "?zoneID=" + strconv.Itoa(zone)
+}
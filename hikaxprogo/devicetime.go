@@ -0,0 +1,149 @@
+package hikaxprogo
+
+import (
+	"context"
+	json "encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time modes of the panel clock
+const (
+	TimeModeManual = "manual"
+	TimeModeNTP    = "NTP"
+)
+
+type DeviceTimeData struct {
+	Time DeviceClock `json:"Time"`
+}
+
+// DeviceClock is the clock configuration of the panel
+type DeviceClock struct {
+	TimeMode  string `json:"timeMode"`
+	LocalTime string `json:"localTime"` // e.g. 2024-05-01T10:00:00+02:00
+	TimeZone  string `json:"timeZone"`  // POSIX style with inverted sign, e.g. CST-2:00:00 for UTC+2
+}
+
+// Time returns the panel time, a local time without offset is interpreted in the panel time zone
+func (c DeviceClock) Time() (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, c.LocalTime); err == nil {
+		return t, nil
+	}
+	loc, err := c.Location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", c.LocalTime, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid device time %q", c.LocalTime)
+	}
+	return t, nil
+}
+
+// Location returns the panel time zone as a fixed offset zone
+func (c DeviceClock) Location() (*time.Location, error) {
+	// skip the zone name, e.g. "CST" in "CST-2:00:00"
+	i := strings.IndexAny(c.TimeZone, "+-0123456789")
+	if i < 0 {
+		return time.UTC, nil
+	}
+	spec := c.TimeZone[i:]
+	sign := 1
+	switch spec[0] {
+	case '-':
+		// POSIX offsets are west of UTC, so "-" means ahead of UTC
+		spec = spec[1:]
+	case '+':
+		sign = -1
+		spec = spec[1:]
+	default:
+		sign = -1
+	}
+	offset := 0
+	for i, part := range strings.SplitN(spec, ":", 3) {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q", c.TimeZone)
+		}
+		offset += n * []int{3600, 60, 1}[i]
+	}
+	return time.FixedZone(c.TimeZone, sign*offset), nil
+}
+
+// DeviceTime returns the clock configuration and current time of the panel
+func (hik *HikISAPI) DeviceTime(ctx context.Context) (DeviceClock, error) {
+	d := DeviceTimeData{}
+	err := hik.getJSON(ctx, DeviceTime, &d)
+	return d.Time, err
+}
+
+// panelLocation returns the panel time zone, the host time zone when the panel doesn't report it
+func (hik *HikISAPI) panelLocation(ctx context.Context) *time.Location {
+	clock, err := hik.DeviceTime(ctx)
+	if err != nil {
+		return time.Local
+	}
+	loc, err := clock.Location()
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// SetDeviceTime sets the panel clock to t and switches it to manual time mode
+func (hik *HikISAPI) SetDeviceTime(ctx context.Context, t time.Time) error {
+	clock, err := hik.DeviceTime(ctx)
+	if err != nil {
+		return err
+	}
+	loc, err := clock.Location()
+	if err != nil {
+		return err
+	}
+	clock.TimeMode = TimeModeManual
+	clock.LocalTime = t.In(loc).Format("2006-01-02T15:04:05Z07:00")
+	return hik.setDeviceTime(ctx, clock)
+}
+
+// SetTimeZone sets the panel time zone in POSIX notation, e.g. "CST-2:00:00" for UTC+2
+func (hik *HikISAPI) SetTimeZone(ctx context.Context, tz string) error {
+	clock, err := hik.DeviceTime(ctx)
+	if err != nil {
+		return err
+	}
+	clock.TimeZone = tz
+	if _, err := clock.Location(); err != nil {
+		return err
+	}
+	// keep the clock running, only the zone changes
+	clock.LocalTime = ""
+	return hik.setDeviceTime(ctx, clock)
+}
+
+// PosixTimeZone formats the UTC offset of t as a panel time zone, e.g. "CST-2:00:00" for UTC+2
+func PosixTimeZone(t time.Time) string {
+	_, offset := t.Zone()
+	sign := "-"
+	if offset < 0 {
+		sign = "+"
+		offset = -offset
+	}
+	return fmt.Sprintf("CST%s%d:%02d:%02d", sign, offset/3600, offset%3600/60, offset%60)
+}
+
+func (hik *HikISAPI) setDeviceTime(ctx context.Context, clock DeviceClock) error {
+	type deviceTime struct {
+		TimeMode  string `json:"timeMode"`
+		LocalTime string `json:"localTime,omitempty"`
+		TimeZone  string `json:"timeZone"`
+	}
+	body, err := json.Marshal(struct {
+		Time deviceTime `json:"Time"`
+	}{deviceTime(clock)})
+	if err != nil {
+		return err
+	}
+	return hik.control(ctx, "PUT", DeviceTime, string(body))
+}
@@ -0,0 +1,105 @@
+package hikaxprogo
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// the example of RFC 7616 section 3.9.1
+const (
+	rfcUser   = "Mufasa"
+	rfcPass   = "Circle of Life"
+	rfcNonce  = "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"
+	rfcOpaque = "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"
+	rfcCnonce = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+)
+
+func rfcChallenge(algorithm string) string {
+	return `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=` + algorithm +
+		`, nonce="` + rfcNonce + `", opaque="` + rfcOpaque + `"`
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	params, ok := parseDigestChallenge(rfcChallenge("SHA-256"))
+	if !ok {
+		t.Fatal("challenge not recognised")
+	}
+	want := map[string]string{
+		"realm":     "http-auth@example.org",
+		"qop":       "auth, auth-int",
+		"algorithm": "SHA-256",
+		"nonce":     rfcNonce,
+		"opaque":    rfcOpaque,
+	}
+	for key, value := range want {
+		if params[key] != value {
+			t.Errorf("%s = %q, want %q", key, params[key], value)
+		}
+	}
+
+	params, _ = parseDigestChallenge(`Digest realm="a \"quoted\", realm", nonce=abc`)
+	if params["realm"] != `a "quoted", realm` || params["nonce"] != "abc" {
+		t.Errorf("unexpected params %v", params)
+	}
+	if _, ok := parseDigestChallenge(`Basic realm="x"`); ok {
+		t.Error("Basic challenge taken for Digest")
+	}
+}
+
+func TestDigestAuthorization(t *testing.T) {
+	cnonce := newCnonce
+	newCnonce = func() string { return rfcCnonce }
+	defer func() { newCnonce = cnonce }()
+
+	tests := []struct {
+		algorithm string
+		response  string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Add("WWW-Authenticate", `Basic realm="http-auth@example.org"`)
+		header.Add("WWW-Authenticate", rfcChallenge(tt.algorithm))
+		d := &digestAuth{}
+		if err := d.challenge(header); err != nil {
+			t.Fatal(err)
+		}
+		auth := d.authorization(rfcUser, rfcPass, "GET", "/dir/index.html")
+		for _, field := range []string{
+			`response="` + tt.response + `"`,
+			"qop=auth",
+			"nc=00000001",
+			`cnonce="` + rfcCnonce + `"`,
+			`opaque="` + rfcOpaque + `"`,
+		} {
+			if !strings.Contains(auth, field) {
+				t.Errorf("%s: %s lacks %s", tt.algorithm, auth, field)
+			}
+		}
+	}
+}
+
+func TestDigestSessionAlgorithmCase(t *testing.T) {
+	upper := &digestAuth{realm: "r", nonce: "n", algorithm: "SHA-256-SESS", qop: "auth"}
+	lower := &digestAuth{realm: "r", nonce: "n", algorithm: "sha-256-sess", qop: "auth"}
+	cnonce := newCnonce
+	newCnonce = func() string { return "c" }
+	defer func() { newCnonce = cnonce }()
+
+	a := upper.authorization("u", "p", "GET", "/")
+	b := lower.authorization("u", "p", "GET", "/")
+	responseOf := func(auth string) string {
+		_, rest, _ := strings.Cut(auth, `response="`)
+		resp, _, _ := strings.Cut(rest, `"`)
+		return resp
+	}
+	if len(responseOf(a)) != 64 {
+		t.Errorf("SHA-256-SESS answered with %q, want a SHA-256 digest", responseOf(a))
+	}
+	if responseOf(a) != responseOf(b) {
+		t.Error("algorithm case changes the response")
+	}
+}
@@ -0,0 +1,49 @@
+package hikaxprogo
+
+import (
+	"context"
+	xml "encoding/xml"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSearchEventsPanelTimeZone(t *testing.T) {
+	var sent logSearchDescription
+	rt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case DeviceTime:
+			return reply(200, `{"Time":{"timeMode":"manual","localTime":"2024-05-01T12:00:00+02:00","timeZone":"CST-2:00:00"}}`), nil
+		case LogSearch:
+			body, _ := io.ReadAll(req.Body)
+			if err := xml.Unmarshal(body, &sent); err != nil {
+				t.Errorf("parse search: %v", err)
+			}
+			return reply(200, `<CMSearchResult><responseStatusStrg>OK</responseStatusStrg><numOfMatches>1</numOfMatches>`+
+				`<matchList><searchMatchItem><logDescriptor><metaId>log.std-cgi.com/Operation/remoteDisarm</metaId>`+
+				`<StartDateTime>2024-05-01T10:30:00</StartDateTime><userName>admin</userName></logDescriptor></searchMatchItem></matchList>`+
+				`</CMSearchResult>`), nil
+		}
+		return reply(404, ""), nil
+	})
+	hik := New("panel", "", "admin", "secret", WithTransport(rt), WithAuth(AuthDigest))
+
+	from := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+	records, err := hik.SearchEvents(context.Background(), from, from.Add(6*time.Hour), EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.StartTime != "2024-05-01T08:00:00+02:00" || sent.EndTime != "2024-05-01T14:00:00+02:00" {
+		t.Errorf("sent range %s - %s, want panel local time", sent.StartTime, sent.EndTime)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	if want := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC); !records[0].Time.Equal(want) {
+		t.Errorf("record time %v, want %v", records[0].Time, want)
+	}
+	if records[0].Major != LogOperation || records[0].Minor != "remoteDisarm" {
+		t.Errorf("record type %s/%s", records[0].Major, records[0].Minor)
+	}
+}
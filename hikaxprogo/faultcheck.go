@@ -0,0 +1,69 @@
+package hikaxprogo
+
+import "context"
+
+// Fault types of the fault check configuration
+const (
+	FaultACLoss          = "ACLoss"
+	FaultBatteryLow      = "batteryLow"
+	FaultNetworkDown     = "networkDisconnected"
+	FaultDetectorOffline = "detectorOffline"
+	FaultTamper          = "tamper"
+)
+
+type FaultCheckData struct {
+	FaultCheckCfg FaultCheckCfg `json:"FaultCheckCfg"`
+}
+
+// FaultCheckCfg defines which faults the panel checks, which of them block arming and which are reported
+type FaultCheckCfg struct {
+	Enabled  bool            `json:"enabled"`
+	TypeList []FaultTypeList `json:"TypeList"`
+}
+
+type FaultTypeList struct {
+	Type FaultType `json:"Type"`
+}
+
+// FaultType is the policy for a single kind of fault
+type FaultType struct {
+	ID            int    `json:"id"`
+	Type          string `json:"type"`
+	Check         bool   `json:"check"`         // the panel monitors this fault
+	PreventArming bool   `json:"preventArming"` // an active fault blocks arming
+	Notify        bool   `json:"notification"`  // an active fault is reported
+}
+
+// Fault returns the policy for the fault type
+func (c FaultCheckCfg) Fault(faultType string) (FaultType, bool) {
+	for _, t := range c.TypeList {
+		if t.Type.Type == faultType {
+			return t.Type, true
+		}
+	}
+	return FaultType{}, false
+}
+
+// SetFault replaces the policy for the fault type f.Type, it reports false if the panel doesn't know the type
+func (c *FaultCheckCfg) SetFault(f FaultType) bool {
+	for i, t := range c.TypeList {
+		if t.Type.Type == f.Type {
+			f.ID = t.Type.ID
+			c.TypeList[i].Type = f
+			return true
+		}
+	}
+	return false
+}
+
+// GetFaultCheckConfig returns the fault check policy of the panel
+func (hik *HikISAPI) GetFaultCheckConfig(ctx context.Context) (FaultCheckCfg, error) {
+	f := FaultCheckData{}
+	err := hik.getJSON(ctx, FaultCheck, &f)
+	return f.FaultCheckCfg, err
+}
+
+// SetFaultCheckConfig updates the fault check policy of the panel
+func (hik *HikISAPI) SetFaultCheckConfig(ctx context.Context, cfg FaultCheckCfg) error {
+	return hik.putJSON(ctx, FaultCheck, FaultCheckData{FaultCheckCfg: cfg})
+}
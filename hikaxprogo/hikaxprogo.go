@@ -1,6 +1,7 @@
 package hikaxprogo
 
 import (
+	"context"
 	"crypto/sha256"
 	"net/http"
 	"time"
@@ -10,16 +11,30 @@ import (
 	json "encoding/json"
 	xml "encoding/xml"
 	"errors"
+	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type HikISAPI struct {
-	host     string
-	port     string
-	username string
-	password string
-	session  http.Cookie // Session cookie
+	baseURL   string // scheme://host:port of the panel
+	username  string
+	password  string
+	sess      session                      // Login state
+	caps      atomic.Pointer[Capabilities] // Capabilities probed at first login, replaced as a whole
+	probe     sync.Mutex                   // guards probing and nextProbe
+	probing   chan struct{}                // closed when the running capability probe ends, nil when none runs
+	nextProbe time.Time                    // earliest probe after one that failed
+	client    *http.Client                 // Shared client of all requests
+	stream    *http.Client                 // Client of the alert stream, same transport without request timeout
+	auth      AuthMode                     // Configured authentication
+	heartbeat time.Duration                // Session heartbeat interval, zero disables it
+	retry     RetryPolicy
 }
 type sessionCapabilities struct {
 	XMLNS          string `xml:"xmlns,attr"`
@@ -38,21 +53,25 @@ type SessionLogin struct {
 	SessionIDVersion string `xml:"sessionIDVersion"`
 }
 
-func (hik *HikISAPI) getSessionParams() (sessionCapabilities, error) {
+func (hik *HikISAPI) getSessionParams(ctx context.Context) (sessionCapabilities, error) {
 
 	capabilities := sessionCapabilities{}
 
-	resp, err := hik.makeRequest("GET", hik.host+":"+hik.port+Session_Capabilities+hik.username, "")
+	// no relogin here, a 401 means the panel doesn't offer the session login to this user
+	resp, err := hik.do(ctx, "GET", hik.endpoint(Session_Capabilities+hik.username), "")
 	if err != nil {
 		return capabilities, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == 404 || resp.StatusCode == 401 {
+		return capabilities, errNoSessionLogin
+	}
 	// parse xmlns from response
 
 	// Read the response body
-	body, err := io.ReadAll(resp.Body)
+	body, err := readResponse(resp, Session_Capabilities)
 	if err != nil {
-		panic(err)
+		return capabilities, err
 	}
 
 	// Unmarshal the XML from the response into the struct
@@ -90,9 +109,39 @@ func (hik *HikISAPI) encodePassword(cap sessionCapabilities) string {
 
 }
 
-func (hik *HikISAPI) Login() error {
+// errNoSessionLogin is returned by getSessionParams when the panel only speaks Digest
+var errNoSessionLogin = errors.New("session login not offered by the panel")
+
+// Login authenticates to the panel. With Digest there is no session, the
+// credentials are sent with every request.
+func (hik *HikISAPI) Login(ctx context.Context) error {
+	_, _, gen := hik.sess.state()
+	if err := hik.renew(ctx, gen); err != nil {
+		return err
+	}
+	hik.loadCapabilities(ctx)
+	return nil
+}
+
+// login runs the session login, the caller holds the login lock
+func (hik *HikISAPI) login(ctx context.Context) error {
+	if hik.sess.isClosed() {
+		return ErrClosed
+	}
+	if _, digest, _ := hik.sess.state(); digest != nil {
+		return nil
+	}
+	if err := hik.lockedErr(); err != nil {
+		// every failed attempt extends the lock, don't even try
+		return err
+	}
 
-	cap, err := hik.getSessionParams()
+	cap, err := hik.getSessionParams(ctx)
+	if errors.Is(err, errNoSessionLogin) && hik.auth == AuthAuto {
+		// older firmware and hubs only speak Digest
+		hik.useDigest()
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -111,106 +160,239 @@ func (hik *HikISAPI) Login() error {
 	}
 	//get POSIX time
 	dt := time.Now().Unix()
-	sessionLoginUrl := hik.host + ":" + hik.port + Session_Login + "?timeStamp=" + strconv.FormatInt(dt, 10)
+	sessionLoginUrl := hik.endpoint(Session_Login + "?timeStamp=" + strconv.FormatInt(dt, 10))
 
 	strLoginRequest := string((xmlLoginRequest[:]))
-	resp, err := hik.makeRequest("POST", sessionLoginUrl, strLoginRequest)
+	resp, err := hik.do(ctx, "POST", sessionLoginUrl, strLoginRequest)
 
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode == 200 {
-		hik.session = *resp.Cookies()[0]
+		cookie, err := sessionCookie(resp.Cookies())
+		if err != nil {
+			return err
+		}
+		hik.setCookie(cookie)
 		return nil
 	}
+	data, _ := readBody(resp.Body, Session_Login)
+	if err := hik.checkLock(data); err != nil {
+		return err
+	}
+	return responseError(resp.StatusCode, Session_Login, data)
 
-	return errors.New("Login failed")
+}
 
+// makeRequest sends a request following the retry policy: network errors and busy replies
+// are retried with backoff, a 401 renews the credentials a bounded number of times.
+// The reply of the last attempt is returned, a 401 one included.
+func (hik *HikISAPI) makeRequest(ctx context.Context, method string, url string, body string) (*http.Response, error) {
+	logins := 0
+	challenged := false
+	for attempt := 1; ; attempt++ {
+		_, digest, gen := hik.sess.state()
+		resp, err := hik.do(ctx, method, url, body)
+		retry := false
+		switch {
+		case err != nil:
+			retry = hik.retry.retryableError(method, err)
+		case resp.StatusCode == 401 && digest != nil && !challenged:
+			// answer the challenge once, a 401 to fresh credentials means they are wrong
+			resp.Body.Close()
+			challenged = true
+			if err := digest.challenge(resp.Header); err != nil {
+				return nil, err
+			}
+			attempt--
+			continue
+		case resp.StatusCode == 401 && digest == nil && logins < hik.retry.MaxLogins:
+			resp.Body.Close()
+			logins++
+			// Session expired, try to login again unless a concurrent request already did
+			if err := hik.renew(ctx, gen); err != nil {
+				return nil, err
+			}
+			attempt--
+			continue
+		default:
+			retry = hik.retry.retryableStatus(resp.StatusCode)
+		}
+		if !retry || attempt >= hik.retry.MaxAttempts {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err := hik.retry.sleep(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
 }
 
-func (hik *HikISAPI) makeRequest(method string, url string, body string) (*http.Response, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, bytes.NewBuffer([]byte(body)))
+// do sends a single request with the credentials of the current auth mode
+func (hik *HikISAPI) do(ctx context.Context, method string, url string, body string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer([]byte(body)))
 	if err != nil {
 		return nil, err
 	}
-	// req.Header.Add("Accept", "application/xml")
-	// req.Header.Add("Content-Type", "application/xml")
-	req.Header.Add("Cookie", hik.session.String())
-	resp, err := client.Do(req)
+	hik.authorize(req)
+	return hik.client.Do(req)
+}
+
+// authorize adds the session cookie or the Digest credentials to the request
+func (hik *HikISAPI) authorize(req *http.Request) {
+	cookie, digest, _ := hik.sess.state()
+	if digest == nil {
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		return
+	}
+	if auth := digest.authorization(hik.username, hik.password, req.Method, req.URL.RequestURI()); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+}
+
+// getRaw fetches path from the panel and returns the reply body
+func (hik *HikISAPI) getRaw(ctx context.Context, path string) ([]byte, error) {
+	resp, err := hik.makeRequest(ctx, "GET", hik.endpoint(path), "")
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode == 401 {
-		// Session expired, try to login again
-		err := hik.Login()
-		if err != nil {
-			return nil, err
-		}
-		return hik.makeRequest(method, url, body)
-	}
-	return resp, nil
+	defer resp.Body.Close()
+	return readResponse(resp, path)
 }
 
-func (hik *HikISAPI) ZoneStatus() (ZoneList, error) {
+// maxResponseSize limits the replies read into memory, the largest regular ones are event log pages
+const maxResponseSize = 8 << 20
 
-	z := ZoneList{}
+// ErrResponseTooLarge is returned when a reply exceeds the size the panel plausibly sends
+var ErrResponseTooLarge = errors.New("response too large")
 
-	resp, err := hik.makeRequest("GET", hik.host+":"+hik.port+ZoneStatus, "")
+// readBody reads a reply up to maxResponseSize, a truncated or oversized reply is an error
+func readBody(r io.Reader, path string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxResponseSize+1))
 	if err != nil {
-		return z, err
+		return nil, fmt.Errorf("read response of %s: %w", path, err)
 	}
-	defer resp.Body.Close()
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
+	if len(data) > maxResponseSize {
+		return nil, fmt.Errorf("read response of %s: %w", path, ErrResponseTooLarge)
 	}
+	return data, nil
+}
 
-	// Unmarshal the JSON from the response into the struct
-	err = json.Unmarshal(body, &z)
+// readResponse reads the reply body, replies other than 200 are returned as ResponseError
+func readResponse(resp *http.Response, path string) ([]byte, error) {
+	data, err := readBody(resp.Body, path)
 	if err != nil {
-		return z, err
+		return nil, err
 	}
-
-	return z, nil
+	if resp.StatusCode != 200 {
+		return nil, responseError(resp.StatusCode, path, data)
+	}
+	return data, nil
 }
 
-func (hik *HikISAPI) ExDevData() (ExDevData, error) {
-	e := ExDevData{}
-
-	resp, err := hik.makeRequest("GET", hik.host+":"+hik.port+PeripheralsStatus, "")
+// getJSON fetches path from the panel and decodes the JSON reply into v
+func (hik *HikISAPI) getJSON(ctx context.Context, path string, v interface{}) error {
+	body, err := hik.getRaw(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := refusal(path, body); err != nil {
+		return err
+	}
+	err = json.Unmarshal(body, v)
 	if err != nil {
-		return e, err
+		return fmt.Errorf("parse response of %s: %w", path, err)
 	}
+	return nil
+}
 
-	defer resp.Body.Close()
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
+// refusal returns the ResponseError of a 200 reply that carries a failed ResponseStatus,
+// some firmware answers unsupported requests that way. Such a reply decodes into any
+// reply struct without an error, so it is checked before decoding.
+func refusal(path string, body []byte) error {
+	status, err := parseResponseStatus(body)
+	if err != nil || status.StatusCode == 0 || status.StatusCode == StatusOK {
+		return nil
 	}
+	return responseError(200, path, body)
+}
 
-	// Unmarshal the JSON from the response into the struct
-	err = json.Unmarshal(body, &e)
+// getXML fetches path from the panel and decodes the XML reply into v
+func (hik *HikISAPI) getXML(ctx context.Context, path string, v interface{}) error {
+	body, err := hik.getRaw(ctx, path)
 	if err != nil {
-		return e, err
+		return err
+	}
+	if err := refusal(path, body); err != nil {
+		return err
+	}
+	err = xml.Unmarshal(body, v)
+	if err != nil {
+		return fmt.Errorf("parse response of %s: %w", path, err)
 	}
+	return nil
+}
 
-	return e, nil
+func (hik *HikISAPI) ZoneStatus(ctx context.Context) (ZoneList, error) {
+	z := ZoneList{}
+	err := hik.getJSON(ctx, ZoneStatus, &z)
+	return z, err
+}
+
+func (hik *HikISAPI) ExDevData(ctx context.Context) (ExDevData, error) {
+	e := ExDevData{}
+	err := hik.getJSON(ctx, PeripheralsStatus, &e)
+	return e, err
 }
 
-func New(host string, port string, username string, password string) *HikISAPI {
+// New creates a client of the panel, options configure the HTTP client shared by all requests.
+// The host may carry the http:// or https:// scheme and a port, a non-empty port argument replaces
+// the port of the host, without any the default port of the scheme is used.
+func New(host string, port string, username string, password string, options ...Option) *HikISAPI {
 	hik := new(HikISAPI)
-	// check if host starts with http:// or https://
-	if host[0:4] == "http" {
-		hik.host = host
-	} else {
-		hik.host = "http://" + host
+	o := defaultClientOptions()
+	for _, option := range options {
+		option(&o)
+	}
+
+	scheme := "http"
+	if strings.HasPrefix(host, "https://") || o.tls {
+		scheme = "https"
+	}
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "http://"), "https://")
+	host = strings.TrimSuffix(host, "/")
+	if h, _, err := net.SplitHostPort(host); err == nil && port != "" {
+		// an explicit port wins over the one given with the host
+		host = h
+	}
+	if port != "" {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	} else if _, _, err := net.SplitHostPort(host); err != nil && strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		// IPv6 address without port
+		host = "[" + host + "]"
 	}
-	hik.port = port
+	hik.baseURL = (&url.URL{Scheme: scheme, Host: host}).String()
 	hik.username = username
 	hik.password = password
-	hik.session = http.Cookie{}
+	hik.auth = o.auth
+	hik.heartbeat = o.heartbeat
+	hik.retry = o.retry
+	if o.auth == AuthDigest {
+		hik.useDigest()
+	}
+
+	transport := o.newTransport()
+	hik.client = &http.Client{Transport: transport, Timeout: o.timeout}
+	hik.stream = &http.Client{Transport: transport}
 	return hik
 }
+
+// endpoint returns the URL of an ISAPI path on the panel
+func (hik *HikISAPI) endpoint(path string) string {
+	return hik.baseURL + path
+}
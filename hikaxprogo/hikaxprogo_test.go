@@ -0,0 +1,54 @@
+package hikaxprogo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestNewBaseURL(t *testing.T) {
+	tests := []struct {
+		host, port, want string
+	}{
+		{"192.168.1.10", "80", "http://192.168.1.10:80"},
+		{"192.168.1.10:8443", "", "http://192.168.1.10:8443"},
+		{"192.168.1.10:8443", "81", "http://192.168.1.10:81"},
+		{"http://panel:81", "", "http://panel:81"},
+		{"https://panel/", "", "https://panel"},
+		{"fe80::1", "", "http://[fe80::1]"},
+		{"fe80::1", "80", "http://[fe80::1]:80"},
+		{"[fe80::1]:81", "", "http://[fe80::1]:81"},
+	}
+	for _, tt := range tests {
+		if got := New(tt.host, tt.port, "admin", "secret").baseURL; got != tt.want {
+			t.Errorf("New(%q, %q) = %s, want %s", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestGetJSONRefusal(t *testing.T) {
+	body := `{"statusCode":4,"subStatusCode":"notSupport"}`
+	rt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return reply(200, body), nil
+	})
+	hik := New("panel", "", "admin", "secret", WithTransport(rt), WithAuth(AuthDigest))
+
+	if _, err := hik.ZoneStatus(context.Background()); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ZoneStatus: got %v, want ErrNotSupported", err)
+	}
+	if _, err := hik.ExDevData(context.Background()); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ExDevData: got %v, want ErrNotSupported", err)
+	}
+
+	body = `<ResponseStatus><statusCode>2</statusCode><subStatusCode>deviceBusy</subStatusCode></ResponseStatus>`
+	if _, err := hik.ZoneStatus(context.Background()); !errors.Is(err, ErrDeviceBusy) {
+		t.Errorf("ZoneStatus with XML status: got %v, want ErrDeviceBusy", err)
+	}
+
+	body = `{"ZoneList":[{"Zone":{"id":1,"name":"door"}}]}`
+	zones, err := hik.ZoneStatus(context.Background())
+	if err != nil || len(zones.Zones) != 1 || zones.Zones[0].Zone.Name != "door" {
+		t.Errorf("ZoneStatus: got %+v, %v", zones, err)
+	}
+}
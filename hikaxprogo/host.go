@@ -0,0 +1,67 @@
+package hikaxprogo
+
+import "context"
+
+type HostData struct {
+	HostStatus Host `json:"HostStatus"`
+}
+
+// Host is the status of the control panel itself
+type Host struct {
+	ACConnect     bool          `json:"ACConnect"` // mains power present
+	TamperEvident bool          `json:"tamperEvident"`
+	BatteryList   []BatteryList `json:"BatteryList"`
+	Ethernet      Link          `json:"Ethernet"`
+	WiFi          Link          `json:"WiFi"`
+	Mobile        Link          `json:"Mobile"`
+}
+
+type BatteryList struct {
+	Battery Battery `json:"Battery"`
+}
+
+// Battery is the backup battery of the panel
+type Battery struct {
+	ID          int     `json:"id"`
+	Charge      string  `json:"charge"`
+	ChargeValue int     `json:"chargeValue"`
+	Voltage     float64 `json:"voltage"`
+	Status      string  `json:"status"`
+}
+
+// Link is the state of one of the panel's communication channels
+type Link struct {
+	Enabled    bool   `json:"enabled"`
+	Status     string `json:"status"`
+	Signal     int    `json:"signal"`
+	SignalType string `json:"signalType"`
+}
+
+// Connected reports whether the communication channel is up
+func (l Link) Connected() bool {
+	return l.Status == "connected" || l.Status == "online"
+}
+
+// ActiveLink returns the name of the communication channel in use,
+// preferring ethernet over Wi-Fi over cellular, or "offline"
+func (h Host) ActiveLink() string {
+	switch {
+	case h.Ethernet.Connected():
+		return "ethernet"
+	case h.WiFi.Connected():
+		return "wifi"
+	case h.Mobile.Connected():
+		return "mobile"
+	}
+	return "offline"
+}
+
+// HostStatus returns the power, battery, tamper and communication status of the panel
+func (hik *HikISAPI) HostStatus(ctx context.Context) (HostData, error) {
+	h := HostData{}
+	if err := hik.requireFeature(ctx, FeatureHostStatus); err != nil {
+		return h, err
+	}
+	err := hik.getJSON(ctx, HostStatus, &h)
+	return h, err
+}
@@ -0,0 +1,58 @@
+package hikaxprogo
+
+import (
+	"context"
+	xml "encoding/xml"
+)
+
+type NetworkInterfaceList struct {
+	XMLName    xml.Name           `xml:"NetworkInterfaceList"`
+	Interfaces []NetworkInterface `xml:"NetworkInterface"`
+}
+
+// NetworkInterface is a network interface of the panel
+type NetworkInterface struct {
+	ID        int           `xml:"id"`
+	IPAddress IPAddress     `xml:"IPAddress"`
+	Link      InterfaceLink `xml:"Link"`
+	Wireless  *Wireless     `xml:"Wireless"` // nil for wired interfaces
+}
+
+// IPAddress is the IP configuration of an interface
+type IPAddress struct {
+	IPVersion      string `xml:"ipVersion"`
+	AddressingType string `xml:"addressingType"` // "dynamic" (DHCP) or "static"
+	Address        string `xml:"ipAddress"`
+	SubnetMask     string `xml:"subnetMask"`
+	Gateway        string `xml:"DefaultGateway>ipAddress"`
+	PrimaryDNS     string `xml:"PrimaryDNS>ipAddress"`
+	SecondaryDNS   string `xml:"SecondaryDNS>ipAddress"`
+}
+
+// DHCP reports whether the address is assigned by DHCP
+func (a IPAddress) DHCP() bool {
+	return a.AddressingType == "dynamic"
+}
+
+// InterfaceLink is the link layer state of an interface
+type InterfaceLink struct {
+	MACAddress string `xml:"MACAddress"`
+	Status     string `xml:"linkStatus"` // "up" or "down"
+	Speed      int    `xml:"speed"`
+	Duplex     string `xml:"duplex"`
+	MTU        int    `xml:"MTU"`
+}
+
+// Wireless is the Wi-Fi state of a wireless interface
+type Wireless struct {
+	Enabled bool   `xml:"enabled"`
+	SSID    string `xml:"ssid"`
+	RSSI    int    `xml:"signalStrength"` // dBm
+}
+
+// NetworkInterfaces returns the network interfaces of the panel
+func (hik *HikISAPI) NetworkInterfaces(ctx context.Context) ([]NetworkInterface, error) {
+	n := NetworkInterfaceList{}
+	err := hik.getXML(ctx, InterfaceInfo, &n)
+	return n.Interfaces, err
+}
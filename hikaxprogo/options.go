@@ -0,0 +1,208 @@
+package hikaxprogo
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Defaults of the HTTP client shared by all requests to the panel
+const (
+	DefaultTimeout     = 30 * time.Second
+	DefaultDialTimeout = 10 * time.Second
+	DefaultKeepAlive   = 30 * time.Second
+	DefaultIdleTimeout = 90 * time.Second
+)
+
+// Option configures the HTTP client of HikISAPI, see New
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	timeout     time.Duration
+	dialTimeout time.Duration
+	keepAlive   time.Duration
+	idleTimeout time.Duration
+	proxy       func(*http.Request) (*url.URL, error)
+	transport   http.RoundTripper
+	tls         bool
+	rootCAs     *x509.CertPool
+	fingerprint string
+	insecure    bool
+	auth        AuthMode
+	heartbeat   time.Duration
+	retry       RetryPolicy
+}
+
+// WithTimeout limits the duration of a single request including reading the reply,
+// zero disables the limit. The alert stream is not affected.
+func WithTimeout(d time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = d
+	}
+}
+
+// WithDialTimeout limits the time to establish a connection to the panel
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *clientOptions) {
+		o.dialTimeout = d
+	}
+}
+
+// WithKeepAlive sets the TCP keep-alive period, a negative value disables keep-alive probes
+func WithKeepAlive(d time.Duration) Option {
+	return func(o *clientOptions) {
+		o.keepAlive = d
+	}
+}
+
+// WithIdleTimeout sets how long an idle connection is kept for reuse
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *clientOptions) {
+		o.idleTimeout = d
+	}
+}
+
+// WithProxy sends the requests through the given proxy, nil connects directly.
+// By default the proxy is taken from the environment (HTTP_PROXY, NO_PROXY).
+func WithProxy(proxy *url.URL) Option {
+	return func(o *clientOptions) {
+		if proxy == nil {
+			o.proxy = nil
+			return
+		}
+		o.proxy = http.ProxyURL(proxy)
+	}
+}
+
+// WithTransport replaces the transport, e.g. with a fake one in tests.
+// The dial, keep-alive, idle, proxy and TLS verification options are ignored then.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *clientOptions) {
+		o.transport = rt
+	}
+}
+
+// WithTLS connects to the panel over HTTPS, a host given with the https:// scheme does the same
+func WithTLS() Option {
+	return func(o *clientOptions) {
+		o.tls = true
+	}
+}
+
+// WithRootCAs verifies the panel certificate against the given CAs instead of the system ones,
+// e.g. the CA that signed the panel certificate. Implies WithTLS.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(o *clientOptions) {
+		o.tls = true
+		o.rootCAs = pool
+	}
+}
+
+// WithPinnedFingerprint accepts only the panel certificate with the given SHA-256 fingerprint
+// in hex, colons and case don't matter. The certificate chain is not verified then, which
+// makes it the way to trust a self-signed panel certificate. Implies WithTLS.
+func WithPinnedFingerprint(fingerprint string) Option {
+	return func(o *clientOptions) {
+		o.tls = true
+		o.fingerprint = strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+	}
+}
+
+// WithInsecureSkipVerify accepts any panel certificate. The connection is encrypted
+// but not authenticated, use it only when pinning is not an option. Implies WithTLS.
+func WithInsecureSkipVerify() Option {
+	return func(o *clientOptions) {
+		o.tls = true
+		o.insecure = true
+	}
+}
+
+// WithAuth selects the authentication, AuthAuto by default
+func WithAuth(mode AuthMode) Option {
+	return func(o *clientOptions) {
+		o.auth = mode
+	}
+}
+
+// WithHeartbeat sets the interval of the session heartbeat, zero disables it
+func WithHeartbeat(d time.Duration) Option {
+	return func(o *clientOptions) {
+		o.heartbeat = d
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *clientOptions) {
+		if p.MaxAttempts < 1 {
+			p.MaxAttempts = 1
+		}
+		o.retry = p
+	}
+}
+
+func defaultClientOptions() clientOptions {
+	return clientOptions{
+		timeout:     DefaultTimeout,
+		dialTimeout: DefaultDialTimeout,
+		keepAlive:   DefaultKeepAlive,
+		idleTimeout: DefaultIdleTimeout,
+		proxy:       http.ProxyFromEnvironment,
+		heartbeat:   DefaultHeartbeat,
+		retry:       DefaultRetryPolicy,
+	}
+}
+
+// newTransport builds the transport all requests share, so connections to the panel are reused
+func (o clientOptions) newTransport() http.RoundTripper {
+	if o.transport != nil {
+		return o.transport
+	}
+	dialer := &net.Dialer{
+		Timeout:   o.dialTimeout,
+		KeepAlive: o.keepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 o.proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		MaxIdleConnsPerHost:   4, // polling, commands and the alert stream run in parallel
+		IdleConnTimeout:       o.idleTimeout,
+		TLSClientConfig:       o.tlsConfig(),
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// tlsConfig builds the TLS configuration of the transport from the options
+func (o clientOptions) tlsConfig() *tls.Config {
+	cfg := &tls.Config{
+		RootCAs:            o.rootCAs,
+		InsecureSkipVerify: o.insecure,
+	}
+	if o.fingerprint != "" {
+		// the pin replaces the chain verification, panels mostly use self-signed certificates
+		pin := o.fingerprint
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("panel sent no certificate")
+			}
+			sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+			if got := hex.EncodeToString(sum[:]); got != pin {
+				return fmt.Errorf("panel certificate fingerprint %s does not match the pinned %s", got, pin)
+			}
+			return nil
+		}
+	}
+	return cfg
+}
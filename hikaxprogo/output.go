@@ -0,0 +1,75 @@
+package hikaxprogo
+
+import (
+	"context"
+	json "encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type outputsCtrl struct {
+	OutputsCtrl struct {
+		Switch string `json:"switch"` // "open" switches the relay on, "close" switches it off
+	} `json:"OutputsCtrl"`
+}
+
+// Outputs returns the relay outputs with their current state
+func (hik *HikISAPI) Outputs(ctx context.Context) ([]Output, error) {
+	e, err := hik.ExDevData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]Output, 0, len(e.ExDevStatus.OutputList))
+	for _, o := range e.ExDevStatus.OutputList {
+		outputs = append(outputs, o.Output)
+	}
+	return outputs, nil
+}
+
+// Output returns the relay output with the given id
+func (hik *HikISAPI) Output(ctx context.Context, id int) (Output, error) {
+	outputs, err := hik.Outputs(ctx)
+	if err != nil {
+		return Output{}, err
+	}
+	for _, o := range outputs {
+		if o.ID == id {
+			return o, nil
+		}
+	}
+	return Output{}, fmt.Errorf("output %d not found", id)
+}
+
+// SwitchOutput switches the relay output on or off
+func (hik *HikISAPI) SwitchOutput(ctx context.Context, id int, on bool) error {
+	if err := hik.requireFeature(ctx, FeatureOutputsCtrl); err != nil {
+		return err
+	}
+	ctrl := outputsCtrl{}
+	ctrl.OutputsCtrl.Switch = "close"
+	if on {
+		ctrl.OutputsCtrl.Switch = "open"
+	}
+	body, err := json.Marshal(ctrl)
+	if err != nil {
+		return err
+	}
+	if err := hik.control(ctx, "PUT", OutputControl+strconv.Itoa(id), string(body)); err != nil {
+		return fmt.Errorf("output %d: %w", id, err)
+	}
+	return nil
+}
+
+// PulseOutput switches the relay output on for d and then off again, it blocks until the pulse ends.
+// The relay is switched off even when ctx is cancelled during the pulse.
+func (hik *HikISAPI) PulseOutput(ctx context.Context, id int, d time.Duration) error {
+	if err := hik.SwitchOutput(ctx, id, true); err != nil {
+		return err
+	}
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+	return hik.SwitchOutput(context.WithoutCancel(ctx), id, false)
+}
@@ -2,14 +2,113 @@ package hikaxprogo
 
 // ExDevStatus Define the data structures
 type ExDevStatus struct {
-	OutputModList   []interface{} `json:"OutputModList"`
-	OutputList      []interface{} `json:"OutputList"`
-	SirenList       []SirenList   `json:"SirenList"`
-	RepeaterList    []interface{} `json:"RepeaterList"`
-	CardReaderList  []interface{} `json:"CardReaderList"`
-	KeypadList      []interface{} `json:"KeypadList"`
-	RemoteList      []interface{} `json:"RemoteList"`
-	TransmitterList []interface{} `json:"TransmitterList"`
+	OutputModList   []OutputModList   `json:"OutputModList"`
+	OutputList      []OutputList      `json:"OutputList"`
+	SirenList       []SirenList       `json:"SirenList"`
+	RepeaterList    []RepeaterList    `json:"RepeaterList"`
+	CardReaderList  []CardReaderList  `json:"CardReaderList"`
+	KeypadList      []KeypadList      `json:"KeypadList"`
+	RemoteList      []RemoteList      `json:"RemoteList"`
+	TransmitterList []TransmitterList `json:"TransmitterList"`
+}
+
+// Peripheral holds the status fields shared by all wireless peripherals
+type Peripheral struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Seq           string `json:"seq"`
+	Status        string `json:"status"` // "online", "offline", ...
+	TamperEvident bool   `json:"tamperEvident"`
+	Charge        string `json:"charge"`
+	ChargeValue   int    `json:"chargeValue"`
+	Signal        int    `json:"signal"`
+	RealSignal    int    `json:"realSignal"`
+	SignalType    string `json:"signalType"`
+	Model         string `json:"model"`
+	Temperature   int    `json:"temperature"`
+	IsViaRepeater bool   `json:"isViaRepeater"`
+	Version       string `json:"version"`
+	DeviceNo      int    `json:"deviceNo"`
+	AbnormalOrNot bool   `json:"abnormalOrNot"`
+}
+
+// Online reports whether the panel can reach the peripheral
+func (p Peripheral) Online() bool {
+	return p.Status != "" && p.Status != "offline"
+}
+
+type OutputModList struct {
+	OutputModule OutputModule `json:"OutputModule"`
+}
+
+// OutputModule is a wired or wireless output expander with several relays
+type OutputModule struct {
+	Peripheral
+	OutputNum int `json:"outputNum"`
+}
+
+type OutputList struct {
+	Output Output `json:"Output"`
+}
+
+// Output is a single relay output
+type Output struct {
+	Peripheral
+	OutputStatus  string `json:"outputStatus"` // relay state, "on" or "off"
+	ModuleID      int    `json:"moduleID"`
+	SubSystemList []int  `json:"subSystemList"`
+}
+
+// On reports whether the relay is switched on
+func (o Output) On() bool {
+	return o.OutputStatus == "on" || o.OutputStatus == "open"
+}
+
+type RepeaterList struct {
+	Repeater Repeater `json:"Repeater"`
+}
+
+type Repeater struct {
+	Peripheral
+}
+
+type CardReaderList struct {
+	CardReader CardReader `json:"CardReader"`
+}
+
+type CardReader struct {
+	Peripheral
+	SubSystemList []int `json:"subSystemList"`
+}
+
+type KeypadList struct {
+	Keypad Keypad `json:"Keypad"`
+}
+
+type Keypad struct {
+	Peripheral
+	SubSystemList []int `json:"subSystemList"`
+}
+
+type RemoteList struct {
+	Remote Remote `json:"Remote"`
+}
+
+// Remote is a keyfob
+type Remote struct {
+	Peripheral
+	SubSystemList []int  `json:"subSystemList"`
+	UserName      string `json:"userName"`
+}
+
+type TransmitterList struct {
+	Transmitter Transmitter `json:"Transmitter"`
+}
+
+// Transmitter is a wireless transmitter for wired detectors
+type Transmitter struct {
+	Peripheral
+	SubSystemList []int `json:"subSystemList"`
 }
 
 type SirenList struct {
@@ -40,3 +139,27 @@ type Siren struct {
 type ExDevData struct {
 	ExDevStatus ExDevStatus `json:"ExDevStatus"`
 }
+
+// SubSystems returns the distinct area numbers the peripherals are linked to
+func (e ExDevStatus) SubSystems() []int {
+	var ids []int
+	for _, siren := range e.SirenList {
+		ids = append(ids, siren.Siren.SubSystemList...)
+	}
+	for _, output := range e.OutputList {
+		ids = append(ids, output.Output.SubSystemList...)
+	}
+	for _, reader := range e.CardReaderList {
+		ids = append(ids, reader.CardReader.SubSystemList...)
+	}
+	for _, keypad := range e.KeypadList {
+		ids = append(ids, keypad.Keypad.SubSystemList...)
+	}
+	for _, remote := range e.RemoteList {
+		ids = append(ids, remote.Remote.SubSystemList...)
+	}
+	for _, transmitter := range e.TransmitterList {
+		ids = append(ids, transmitter.Transmitter.SubSystemList...)
+	}
+	return mergeAreas(ids)
+}
@@ -0,0 +1,81 @@
+package hikaxprogo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy decides how often and how fast failed requests are repeated
+type RetryPolicy struct {
+	MaxAttempts int           // attempts per request including the first, 1 disables retries
+	MaxLogins   int           // logins per request after the panel answered 401
+	BaseDelay   time.Duration // delay before the first retry, doubled for every further one
+	MaxDelay    time.Duration // delay cap
+	RetryStatus []int         // HTTP statuses worth another attempt, e.g. 503 while the panel is busy
+}
+
+// DefaultRetryPolicy is used unless WithRetryPolicy sets another one
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MaxLogins:   1,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	RetryStatus: []int{502, 503, 504},
+}
+
+// delay returns the randomised wait before the given retry, so that several
+// clients of a busy panel don't retry in lockstep
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay << (retry - 1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryableStatus reports whether a reply with the HTTP status is worth another attempt
+func (p RetryPolicy) retryableStatus(status int) bool {
+	return slices.Contains(p.RetryStatus, status)
+}
+
+// retryableError reports whether a failed request is worth another attempt. Requests
+// that never reached the panel are always repeated, others only when reading, as
+// repeating a command could e.g. switch an output twice.
+func (p RetryPolicy) retryableError(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	if method != "GET" {
+		return false
+	}
+	var netErr net.Error
+	return (errors.As(err, &netErr) && netErr.Timeout()) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// sleep waits before the given retry, it returns early with the context error
+func (p RetryPolicy) sleep(ctx context.Context, retry int) error {
+	t := time.NewTimer(p.delay(retry))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
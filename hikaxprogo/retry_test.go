@@ -0,0 +1,184 @@
+package hikaxprogo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakePanel counts the requests by method and path and answers them with handle
+type fakePanel struct {
+	mu     sync.Mutex
+	counts map[string]int
+	handle func(req *http.Request) (*http.Response, error)
+}
+
+func (p *fakePanel) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.mu.Lock()
+	if p.counts == nil {
+		p.counts = map[string]int{}
+	}
+	p.counts[req.Method+" "+req.URL.Path]++
+	p.mu.Unlock()
+	return p.handle(req)
+}
+
+func (p *fakePanel) count(key string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.counts[key]
+}
+
+const sessionCapsReply = `<SessionLoginCap><sessionID>id</sessionID><challenge>c</challenge><iterations>2</iterations></SessionLoginCap>`
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, MaxLogins: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, RetryStatus: []int{503}}
+
+func newTestPanel(p *fakePanel, policy RetryPolicy, options ...Option) *HikISAPI {
+	options = append(options, WithTransport(p), WithRetryPolicy(policy), WithHeartbeat(0))
+	return New("panel", "", "admin", "secret", options...)
+}
+
+func TestMakeRequestStopsAfterMaxLogins(t *testing.T) {
+	for _, maxLogins := range []int{1, 2} {
+		p := &fakePanel{handle: func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/ISAPI/Security/sessionLogin/capabilities":
+				return reply(200, sessionCapsReply), nil
+			case Session_Login:
+				resp := reply(200, "")
+				resp.Header.Add("Set-Cookie", "WebSession_1=abc; Path=/")
+				return resp, nil
+			}
+			return reply(401, ""), nil
+		}}
+		policy := fastRetry
+		policy.MaxLogins = maxLogins
+		hik := newTestPanel(p, policy)
+
+		_, err := hik.getRaw(context.Background(), Caps)
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("max logins %d: got %v, want ErrInvalidCredentials", maxLogins, err)
+		}
+		if n := p.count("POST " + Session_Login); n != maxLogins {
+			t.Errorf("max logins %d: logged in %d times", maxLogins, n)
+		}
+		if n := p.count("GET " + Caps); n != maxLogins+1 {
+			t.Errorf("max logins %d: sent the request %d times, want %d", maxLogins, n, maxLogins+1)
+		}
+	}
+}
+
+func TestMakeRequestAnswersDigestChallengeOnce(t *testing.T) {
+	p := &fakePanel{handle: func(req *http.Request) (*http.Response, error) {
+		resp := reply(401, "")
+		resp.Header.Add("WWW-Authenticate", `Digest realm="panel", qop="auth", nonce="n1"`)
+		return resp, nil
+	}}
+	hik := newTestPanel(p, fastRetry, WithAuth(AuthDigest))
+
+	_, err := hik.getRaw(context.Background(), Caps)
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("got %v, want ErrInvalidCredentials", err)
+	}
+	if n := p.count("GET " + Caps); n != 2 {
+		t.Errorf("sent the request %d times, want 2", n)
+	}
+}
+
+func TestLoginReportsAccountLock(t *testing.T) {
+	p := &fakePanel{handle: func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/ISAPI/Security/sessionLogin/capabilities":
+			return reply(200, sessionCapsReply), nil
+		case Session_Login:
+			return reply(401, `<userCheck><statusValue>401</statusValue><lockStatus>lock</lockStatus><unlockTime>120</unlockTime></userCheck>`), nil
+		}
+		return reply(401, ""), nil
+	}}
+	hik := newTestPanel(p, fastRetry)
+
+	_, err := hik.getRaw(context.Background(), Caps)
+	var locked *AccountLockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("got %v, want AccountLockedError", err)
+	}
+	if locked.Remaining != 120*time.Second {
+		t.Errorf("remaining %s, want 2m0s", locked.Remaining)
+	}
+
+	// while locked the panel is not asked again, every attempt would extend the lock
+	_, err = hik.getRaw(context.Background(), Caps)
+	if !errors.As(err, &locked) || locked.Remaining <= 0 || locked.Remaining > 120*time.Second {
+		t.Errorf("got %v, want the remaining lock", err)
+	}
+	if n := p.count("POST " + Session_Login); n != 1 {
+		t.Errorf("logged in %d times while locked, want 1", n)
+	}
+}
+
+func TestCheckLockJSON(t *testing.T) {
+	hik := New("panel", "", "admin", "secret")
+	err := hik.checkLock([]byte(`{"userCheck":{"lockStatus":"lock","unlockTime":30}}`))
+	var locked *AccountLockedError
+	if !errors.As(err, &locked) || locked.Remaining != 30*time.Second {
+		t.Errorf("got %v, want a lock of 30s", err)
+	}
+	if err := hik.checkLock([]byte(`{"userCheck":{"lockStatus":"unlock","unlockTime":0}}`)); err != nil {
+		t.Errorf("unlocked reply reported as %v", err)
+	}
+	if err := hik.checkLock([]byte(`not a reply`)); err != nil {
+		t.Errorf("garbage reported as %v", err)
+	}
+}
+
+func TestMakeRequestRetries(t *testing.T) {
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		status int
+		want   int
+	}{
+		{"failed PUT is not resent", "PUT", reset, 0, 1},
+		{"failed GET is retried", "GET", reset, 0, 3},
+		{"PUT that never reached the panel is retried", "PUT", refused, 0, 3},
+		{"busy panel is retried", "GET", nil, 503, 3},
+		{"other status is not retried", "PUT", nil, 400, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakePanel{handle: func(req *http.Request) (*http.Response, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return reply(tt.status, ""), nil
+			}}
+			hik := newTestPanel(p, fastRetry, WithAuth(AuthDigest))
+			resp, err := hik.makeRequest(context.Background(), tt.method, hik.endpoint(Alarm_ArmAway), "")
+			if err == nil {
+				resp.Body.Close()
+			}
+			if n := p.count(tt.method + " /ISAPI/SecurityCP/control/arm/0xffffffff"); n != tt.want {
+				t.Errorf("sent %d times, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for retry, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := p.delay(retry); d < max/2 || d > max {
+				t.Errorf("retry %d: delay %s outside [%s, %s]", retry, d, max/2, max)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package hikaxprogo
+
+import (
+	"bytes"
+	"context"
+	json "encoding/json"
+	xml "encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHeartbeat is the interval of the session heartbeat that keeps the session from expiring
+const DefaultHeartbeat = 60 * time.Second
+
+// session holds the login state shared by concurrent requests. Logins are serialised,
+// a request that failed with an outdated session doesn't log in again when another
+// caller already renewed it.
+type session struct {
+	login sync.Mutex // held while logging in
+
+	mu     sync.RWMutex // guards the fields below
+	cookie *http.Cookie // nil before the first login and after logout
+	digest *digestAuth  // Digest state, nil while the session login is used
+	gen    int          // incremented by every login
+	stop   chan struct{}
+	// lockedUntil is the end of the anti brute force lock the panel reported
+	lockedUntil time.Time
+	closed      bool // set by Close, no login afterwards
+}
+
+// ErrClosed is returned when a request needs a login after Close
+var ErrClosed = errors.New("client closed")
+
+// ErrAccountLocked is returned when the panel locked the user after failed logins
+var ErrAccountLocked = errors.New("account locked by the panel")
+
+// AccountLockedError reports the lock of the user, it matches ErrAccountLocked
+type AccountLockedError struct {
+	Remaining time.Duration // time until the panel accepts logins again
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("%v, retry in %s", ErrAccountLocked, e.Remaining.Round(time.Second))
+}
+
+func (e *AccountLockedError) Is(target error) bool {
+	return target == ErrAccountLocked
+}
+
+// userCheck is the login failure reply of the panel
+type userCheck struct {
+	LockStatus string `json:"lockStatus" xml:"lockStatus"`
+	UnlockTime int    `json:"unlockTime" xml:"unlockTime"` // seconds until unlocked
+}
+
+// checkLock records a lock reported by a failed login and returns it as AccountLockedError
+func (hik *HikISAPI) checkLock(data []byte) error {
+	check := userCheck{}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '<' {
+		if xml.Unmarshal(data, &check) != nil {
+			return nil
+		}
+	} else {
+		wrapper := struct {
+			UserCheck *userCheck `json:"userCheck"`
+		}{UserCheck: &check}
+		if json.Unmarshal(data, &wrapper) != nil {
+			return nil
+		}
+	}
+	if check.LockStatus != "lock" {
+		return nil
+	}
+	hik.sess.mu.Lock()
+	hik.sess.lockedUntil = time.Now().Add(time.Duration(check.UnlockTime) * time.Second)
+	hik.sess.mu.Unlock()
+	return &AccountLockedError{Remaining: time.Duration(check.UnlockTime) * time.Second}
+}
+
+// lockedErr returns AccountLockedError while the reported lock lasts
+func (hik *HikISAPI) lockedErr() error {
+	hik.sess.mu.RLock()
+	defer hik.sess.mu.RUnlock()
+	remaining := time.Until(hik.sess.lockedUntil)
+	if remaining <= 0 {
+		return nil
+	}
+	return &AccountLockedError{Remaining: remaining}
+}
+
+// state returns the current credentials and their generation
+func (s *session) state() (cookie *http.Cookie, digest *digestAuth, gen int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cookie, s.digest, s.gen
+}
+
+// renew logs in unless another caller did since gen
+func (hik *HikISAPI) renew(ctx context.Context, gen int) error {
+	hik.sess.login.Lock()
+	defer hik.sess.login.Unlock()
+	if _, _, current := hik.sess.state(); current != gen {
+		return nil
+	}
+	return hik.login(ctx)
+}
+
+// isClosed reports whether Close was called
+func (s *session) isClosed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.closed
+}
+
+// setCookie stores the session of a successful login and starts the heartbeat, never after Close
+func (hik *HikISAPI) setCookie(cookie *http.Cookie) {
+	hik.sess.mu.Lock()
+	defer hik.sess.mu.Unlock()
+	hik.sess.cookie = cookie
+	hik.sess.gen++
+	if hik.sess.stop == nil && hik.heartbeat > 0 && !hik.sess.closed {
+		hik.sess.stop = make(chan struct{})
+		go hik.keepAlive(hik.sess.stop)
+	}
+}
+
+// useDigest switches to Digest authentication
+func (hik *HikISAPI) useDigest() {
+	hik.sess.mu.Lock()
+	defer hik.sess.mu.Unlock()
+	if hik.sess.digest == nil {
+		hik.sess.digest = &digestAuth{}
+	}
+	hik.sess.gen++
+}
+
+// keepAlive sends the session heartbeat until stop is closed. A heartbeat refused
+// because the session expired anyway logs in again.
+func (hik *HikISAPI) keepAlive(stop <-chan struct{}) {
+	ticker := time.NewTicker(hik.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), hik.heartbeat)
+		resp, err := hik.makeRequest(ctx, "PUT", hik.endpoint(Session_Heartbeat), "")
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+	}
+}
+
+// Close stops the heartbeat and logs out, so the session doesn't occupy one of the
+// few session slots of the panel until it expires. Requests that need a login fail
+// with ErrClosed afterwards.
+func (hik *HikISAPI) Close() error {
+	hik.sess.login.Lock()
+	defer hik.sess.login.Unlock()
+
+	hik.sess.mu.Lock()
+	hik.sess.closed = true
+	cookie := hik.sess.cookie
+	if hik.sess.stop != nil {
+		close(hik.sess.stop)
+		hik.sess.stop = nil
+	}
+	hik.sess.mu.Unlock()
+	defer hik.client.CloseIdleConnections()
+
+	if cookie == nil {
+		return nil
+	}
+	resp, err := hik.do(context.Background(), "PUT", hik.endpoint(Session_Logout), "")
+	hik.sess.mu.Lock()
+	hik.sess.cookie = nil
+	hik.sess.gen++
+	hik.sess.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return errors.New("logout failed: " + resp.Status)
+	}
+	return nil
+}
+
+// sessionCookie picks the session cookie of the login reply, the panel may set other cookies too
+func sessionCookie(cookies []*http.Cookie) (*http.Cookie, error) {
+	for _, c := range cookies {
+		if strings.HasPrefix(c.Name, "WebSession") {
+			return c, nil
+		}
+	}
+	return nil, errors.New("login reply has no session cookie")
+}
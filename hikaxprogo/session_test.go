@@ -0,0 +1,57 @@
+package hikaxprogo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCloseStopsLogins(t *testing.T) {
+	p := &fakePanel{handle: func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/ISAPI/Security/sessionLogin/capabilities":
+			return reply(200, sessionCapsReply), nil
+		case Session_Login:
+			resp := reply(200, "")
+			resp.Header.Add("Set-Cookie", "WebSession_1=abc; Path=/")
+			return resp, nil
+		case Session_Logout:
+			return reply(200, ""), nil
+		}
+		if _, err := req.Cookie("WebSession_1"); err != nil {
+			return reply(401, ""), nil
+		}
+		return reply(200, "{}"), nil
+	}}
+	policy := fastRetry
+	policy.MaxLogins = 2
+	hik := New("panel", "", "admin", "secret", WithTransport(p), WithRetryPolicy(policy), WithHeartbeat(time.Hour))
+
+	if err := hik.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := hik.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.count("PUT " + Session_Logout); n != 1 {
+		t.Errorf("logged out %d times, want 1", n)
+	}
+
+	// e.g. a heartbeat or poll that was waiting for the login lock during Close
+	if _, err := hik.getRaw(context.Background(), Caps); !errors.Is(err, ErrClosed) {
+		t.Errorf("request after Close: got %v, want ErrClosed", err)
+	}
+	if err := hik.Login(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Login after Close: got %v, want ErrClosed", err)
+	}
+	if n := p.count("POST " + Session_Login); n != 1 {
+		t.Errorf("logged in %d times, want only the login before Close", n)
+	}
+	hik.sess.mu.RLock()
+	defer hik.sess.mu.RUnlock()
+	if hik.sess.stop != nil {
+		t.Error("heartbeat restarted after Close")
+	}
+}
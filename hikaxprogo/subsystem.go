@@ -0,0 +1,127 @@
+package hikaxprogo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Arming states reported for an area (subsystem)
+const (
+	ArmingAway     = "away"
+	ArmingStay     = "stay"
+	ArmingVacation = "vacation"
+	ArmingDisarm   = "disarm"
+	ArmingArming   = "arming"
+)
+
+type SubSystemList struct {
+	SubSysList []SubSysList `json:"SubSysList"`
+}
+
+type SubSysList struct {
+	SubSys SubSys `json:"SubSys"`
+}
+
+// SubSys is the live status of a single area (subsystem)
+type SubSys struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Arming    string `json:"arming"`
+	Alarm     bool   `json:"alarm"`
+	Fault     bool   `json:"fault"`
+	Tamper    bool   `json:"tamperEvident"`
+	DelayType string `json:"delayType"` // "entry" or "exit" while a delay is running
+	DelayTime int    `json:"delayTime"` // remaining entry/exit delay in seconds
+}
+
+// Armed reports whether the area is armed in any mode
+func (s SubSys) Armed() bool {
+	return s.Arming == ArmingAway || s.Arming == ArmingStay || s.Arming == ArmingVacation
+}
+
+// SubSystemStatus returns the arm and alarm status of every area
+func (hik *HikISAPI) SubSystemStatus(ctx context.Context) (SubSystemList, error) {
+	s := SubSystemList{}
+	if err := hik.requireFeature(ctx, FeatureSubSystemStatus); err != nil {
+		return s, err
+	}
+	err := hik.getJSON(ctx, SubSystemStatus, &s)
+	return s, err
+}
+
+// Arm rules of a public area (subsystem)
+const (
+	PublicArmAll = "allArmed" // armed when all linked areas are armed
+	PublicArmAny = "anyArmed" // armed when any linked area is armed
+)
+
+type PublicSubSysData struct {
+	PublicSubSysList []PublicSubSysList `json:"PublicSubSysList"`
+}
+
+type PublicSubSysList struct {
+	PublicSubSys PublicSubSys `json:"PublicSubSys"`
+}
+
+// PublicSubSys is a public area, e.g. a shared hallway, that follows the arm state of its linked private areas
+type PublicSubSys struct {
+	ID              int    `json:"id"` // area (subsystem) number of the public area
+	Enabled         bool   `json:"enabled"`
+	LinkedSubSystem []int  `json:"linkedSubSystem"`
+	ArmRule         string `json:"armRule"`
+}
+
+// DerivedArming returns the arm state the public area follows from the state of its linked areas:
+// away when the linked areas that count are all armed away, stay when some are armed stay, disarm otherwise
+func (p PublicSubSys) DerivedArming(areas []SubSys) string {
+	armed, away, linked := 0, 0, 0
+	for _, a := range areas {
+		if !slices.Contains(p.LinkedSubSystem, a.ID) {
+			continue
+		}
+		linked++
+		if a.Armed() {
+			armed++
+		}
+		if a.Arming == ArmingAway {
+			away++
+		}
+	}
+	switch {
+	case linked == 0 || armed == 0:
+		return ArmingDisarm
+	case p.ArmRule != PublicArmAny && armed < linked:
+		return ArmingDisarm
+	case away == armed:
+		return ArmingAway
+	}
+	return ArmingStay
+}
+
+// PublicSubSystems returns the public areas and their linked private areas
+func (hik *HikISAPI) PublicSubSystems(ctx context.Context) ([]PublicSubSys, error) {
+	p := PublicSubSysData{}
+	err := hik.getJSON(ctx, PublicSubSystem, &p)
+	res := make([]PublicSubSys, 0, len(p.PublicSubSysList))
+	for _, s := range p.PublicSubSysList {
+		res = append(res, s.PublicSubSys)
+	}
+	return res, err
+}
+
+// SetPublicSubSystems updates the membership and arm rules of the public areas
+func (hik *HikISAPI) SetPublicSubSystems(ctx context.Context, list []PublicSubSys) error {
+	p := PublicSubSysData{}
+	for _, s := range list {
+		if s.ArmRule != "" && s.ArmRule != PublicArmAll && s.ArmRule != PublicArmAny {
+			return fmt.Errorf("public area %d: arm rule %q: %w", s.ID, s.ArmRule, ErrInvalidConfig)
+		}
+		if slices.Contains(s.LinkedSubSystem, s.ID) {
+			return fmt.Errorf("public area %d is linked to itself: %w", s.ID, ErrInvalidConfig)
+		}
+		p.PublicSubSysList = append(p.PublicSubSysList, PublicSubSysList{PublicSubSys: s})
+	}
+	return hik.putJSON(ctx, PublicSubSystem, p)
+}
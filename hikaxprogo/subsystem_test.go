@@ -0,0 +1,42 @@
+package hikaxprogo
+
+import "testing"
+
+func TestDerivedArming(t *testing.T) {
+	areas := func(states ...string) []SubSys {
+		var res []SubSys
+		for i, s := range states {
+			res = append(res, SubSys{ID: i + 1, Enabled: true, Arming: s})
+		}
+		return res
+	}
+	tests := []struct {
+		name   string
+		rule   string
+		linked []int
+		areas  []SubSys
+		want   string
+	}{
+		{"no linked areas", PublicArmAll, nil, areas(ArmingAway, ArmingAway), ArmingDisarm},
+		{"linked areas missing", PublicArmAny, []int{7, 8}, areas(ArmingAway, ArmingAway), ArmingDisarm},
+		{"all away", PublicArmAll, []int{1, 2}, areas(ArmingAway, ArmingAway), ArmingAway},
+		{"all, one disarmed", PublicArmAll, []int{1, 2}, areas(ArmingAway, ArmingDisarm), ArmingDisarm},
+		{"all, still arming", PublicArmAll, []int{1, 2}, areas(ArmingAway, ArmingArming), ArmingDisarm},
+		{"all, mixed stay and away", PublicArmAll, []int{1, 2}, areas(ArmingAway, ArmingStay), ArmingStay},
+		{"all stay", PublicArmAll, []int{1, 2}, areas(ArmingStay, ArmingStay), ArmingStay},
+		{"all, vacation counts as stay", PublicArmAll, []int{1, 2}, areas(ArmingAway, ArmingVacation), ArmingStay},
+		{"rule unset is all", "", []int{1, 2}, areas(ArmingAway, ArmingDisarm), ArmingDisarm},
+		{"any, one away", PublicArmAny, []int{1, 2}, areas(ArmingAway, ArmingDisarm), ArmingAway},
+		{"any, one stay", PublicArmAny, []int{1, 2}, areas(ArmingDisarm, ArmingStay), ArmingStay},
+		{"any, mixed stay and away", PublicArmAny, []int{1, 2, 3}, areas(ArmingAway, ArmingStay, ArmingDisarm), ArmingStay},
+		{"any, none armed", PublicArmAny, []int{1, 2}, areas(ArmingDisarm, ArmingDisarm), ArmingDisarm},
+		{"unlinked areas ignored", PublicArmAll, []int{1}, areas(ArmingAway, ArmingDisarm), ArmingAway},
+		{"unlinked armed area ignored", PublicArmAny, []int{2}, areas(ArmingAway, ArmingDisarm), ArmingDisarm},
+	}
+	for _, tt := range tests {
+		p := PublicSubSys{ID: 9, Enabled: true, LinkedSubSystem: tt.linked, ArmRule: tt.rule}
+		if got := p.DerivedArming(tt.areas); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package hikaxprogo
 
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
 type ZoneList struct {
 	Zones []struct {
 		Zone struct {
@@ -32,3 +38,61 @@ type ZoneList struct {
 		} `json:"Zone"`
 	} `json:"ZoneList"`
 }
+
+// SubSystems returns the distinct area numbers the zones belong to
+func (z ZoneList) SubSystems() []int {
+	var ids []int
+	for _, zone := range z.Zones {
+		ids = append(ids, zone.Zone.SubSystemNo)
+	}
+	return mergeAreas(ids)
+}
+
+// Zone types of the zone configuration
+const (
+	ZoneTypeInstant   = "Instant"
+	ZoneTypeDelay     = "Delay"
+	ZoneTypeFollow    = "Follow"
+	ZoneType24Hour    = "24hNoSound"
+	ZoneTypeEmergency = "Emergency"
+	ZoneTypeFire      = "Fire"
+	ZoneTypeGas       = "Gas"
+	ZoneTypeMedical   = "Medical"
+	ZoneTypeTimeout   = "Timeout"
+	ZoneTypeNonAlarm  = "Non-Alarm"
+)
+
+type ZoneConfigData struct {
+	Zone ZoneConfig `json:"Zone"`
+}
+
+// ZoneConfig is the configuration of a single zone
+type ZoneConfig struct {
+	ID               int    `json:"id"`
+	Name             string `json:"zoneName"`
+	ZoneType         string `json:"zoneType"`
+	DetectorType     string `json:"detectorType,omitempty"`
+	ChimeEnabled     bool   `json:"chimeEnabled"`
+	StayAway         bool   `json:"stayAway"` // the zone is ignored while stay armed
+	EntryDelay       int    `json:"entryDelay"`
+	ExitDelay        int    `json:"exitDelay"`
+	SubSystemNo      int    `json:"subSystemNo"`
+	LinkageSubSystem []int  `json:"linkageSubSystem"`
+	SirenLinkage     []int  `json:"sirenLinkage"` // sirens sounding on alarm of the zone
+}
+
+// GetZoneConfig returns the configuration of the zone
+func (hik *HikISAPI) GetZoneConfig(ctx context.Context, id int) (ZoneConfig, error) {
+	z := ZoneConfigData{}
+	err := hik.getJSON(ctx, Zones+strconv.Itoa(id), &z)
+	return z.Zone, err
+}
+
+// SetZoneConfig updates the configuration of the zone cfg.ID,
+// the configuration is validated against the zone capabilities before sending
+func (hik *HikISAPI) SetZoneConfig(ctx context.Context, cfg ZoneConfig) error {
+	if err := hik.validateConfig(ctx, func(c Capabilities) []byte { return c.Zones }, cfg); err != nil {
+		return fmt.Errorf("zone %d: %w", cfg.ID, err)
+	}
+	return hik.putJSON(ctx, Zones+strconv.Itoa(cfg.ID), ZoneConfigData{Zone: cfg})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/go-pkgz/lgr"
+	"github.com/i39/hikaxprogo"
+)
+
+// refusedError is returned when arming is blocked by faults or open zones
+type refusedError struct {
+	reasons []string
+	err     error
+}
+
+func (e *refusedError) Error() string {
+	return "arming refused: " + strings.Join(e.reasons, "; ")
+}
+
+func (e *refusedError) Unwrap() error {
+	return e.err
+}
+
+// armArea arms an area in "away" or "stay" mode or disarms it ("disarm"), area 0 means all areas.
+// When the panel refuses to arm, the error carries the reasons of the pre-arm check.
+func armArea(ctx context.Context, id int, mode string) error {
+	var arm func(ctx context.Context) error
+	switch mode {
+	case hikaxprogo.ArmingAway:
+		arm = func(ctx context.Context) error { return hik.ArmAwayAreas(ctx, id) }
+		if id == 0 {
+			arm = hik.ArmAway
+		}
+	case hikaxprogo.ArmingStay:
+		arm = func(ctx context.Context) error { return hik.ArmStayAreas(ctx, id) }
+		if id == 0 {
+			arm = hik.ArmStay
+		}
+	case hikaxprogo.ArmingDisarm:
+		arm = func(ctx context.Context) error { return hik.DisarmAreas(ctx, id) }
+		if id == 0 {
+			arm = hik.Disarm
+		}
+	default:
+		return fmt.Errorf("invalid arming mode %q", mode)
+	}
+
+	log.Printf("[INFO] %s area %d", mode, id)
+	// the panel applies its own arming policy, e.g. faults that only notify don't block arming
+	if err := arm(ctx); err != nil {
+		// the panel refused, ask it why
+		if mode != hikaxprogo.ArmingDisarm {
+			if reasons := preArmCheck(ctx, id); len(reasons) > 0 {
+				return &refusedError{reasons: reasons, err: err}
+			}
+		}
+		return err
+	}
+	requestRefresh()
+	return nil
+}
+
+// preArmCheck returns what blocks arming of an area, area 0 means all areas
+func preArmCheck(ctx context.Context, id int) []string {
+	var areas []int
+	if id != 0 {
+		areas = append(areas, id)
+	}
+	check, err := hik.PreArmCheck(ctx, areas...)
+	if err != nil {
+		if !errors.Is(err, hikaxprogo.ErrNotSupported) {
+			log.Printf("[WARN] pre-arm check failed: %v", err)
+		}
+		return nil
+	}
+	return check.Reasons()
+}
+
+// bypassZone bypasses a zone or recovers it from bypass and schedules a refresh
+func bypassZone(ctx context.Context, id int, bypass bool) error {
+	var err error
+	if bypass {
+		log.Printf("[INFO] bypass zone %d", id)
+		err = hik.BypassZones(ctx, id)
+	} else {
+		log.Printf("[INFO] recover bypass of zone %d", id)
+		err = hik.RecoverBypass(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	requestRefresh()
+	return nil
+}
+
+// switchRelay switches a relay output on or off and schedules a refresh
+func switchRelay(ctx context.Context, id int, on bool) error {
+	log.Printf("[INFO] switch relay %d %s", id, switchState(on))
+	if err := hik.SwitchOutput(ctx, id, on); err != nil {
+		return err
+	}
+	requestRefresh()
+	return nil
+}
+
+// toggleRelay switches a relay output to the opposite of its last known state
+func toggleRelay(ctx context.Context, id int) error {
+	on := false
+	found := false
+	mu.Lock()
+	for _, d := range deviceInfoList {
+		if d.Type == "relay" && d.ID == id {
+			on, found = d.State == "ON", true
+			break
+		}
+	}
+	mu.Unlock()
+	if !found {
+		return fmt.Errorf("relay %d not found", id)
+	}
+	return switchRelay(ctx, id, !on)
+}
+
+// maxPulseSeconds limits relay pulses, the command blocks for the whole pulse
+// and a restart of hikhello during the pulse leaves the relay on
+const maxPulseSeconds = 60
+
+// pulseRelay switches a relay output on for the given number of seconds
+func pulseRelay(ctx context.Context, id int, seconds int) error {
+	if seconds <= 0 || seconds > maxPulseSeconds {
+		return fmt.Errorf("invalid pulse length %d, allowed are 1 to %d seconds", seconds, maxPulseSeconds)
+	}
+	log.Printf("[INFO] pulse relay %d for %ds", id, seconds)
+	if err := hik.PulseOutput(ctx, id, time.Duration(seconds)*time.Second); err != nil {
+		return err
+	}
+	requestRefresh()
+	return nil
+}
+
+// requestRefresh asks fetchData to poll the device without waiting for the polling interval
+func requestRefresh() {
+	select {
+	case refreshData <- true:
+	default:
+	}
+}
+
+// parseSwitch parses an on/off command payload
+func parseSwitch(payload string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(payload)) {
+	case "on", "true", "1":
+		return true, nil
+	case "off", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid switch value %q", payload)
+}
+
+// switchState formats a relay or bypass state the way MQTT switches expect it
+func switchState(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// joinIDs formats a list of zone, area or device numbers as "1,2,3"
+func joinIDs(ids []int) string {
+	s := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s = append(s, strconv.Itoa(id))
+	}
+	return strings.Join(s, ",")
+}
+
+// parseID parses a zone, area or device number from a request path or topic
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid id %q", s)
+	}
+	return id, nil
+}
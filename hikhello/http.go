@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
 	log "github.com/go-pkgz/lgr"
+	"github.com/i39/hikaxprogo"
 	"html/template"
+	"net"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"strconv"
+	"time"
 )
 
-func httpPoller() error {
-	defer wg.Done()
+func httpPoller(ctx context.Context) error {
 	// Parse the templates
 	mainTplPath := filepath.Join("templates", "main.html")
 	partialTplPath := filepath.Join("templates", "partial.html")
@@ -24,6 +32,16 @@ func httpPoller() error {
 		return fmt.Errorf("error parsing partial template: %v", err)
 	}
 
+	historyTmpl, err := template.ParseFiles(filepath.Join("templates", "history.html"))
+	if err != nil {
+		return fmt.Errorf("error parsing history template: %v", err)
+	}
+
+	faultsTmpl, err := template.ParseFiles(filepath.Join("templates", "faults.html"))
+	if err != nil {
+		return fmt.Errorf("error parsing faults template: %v", err)
+	}
+
 	// HTTP handler to serve the main template
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		err := mainTmpl.Execute(w, nil)
@@ -32,13 +50,107 @@ func httpPoller() error {
 		}
 	})
 
-	// HTTP handler to serve the zone list as partial HTML
+	// HTTP handler to serve the zone, area and network lists as partial HTML
 	http.HandleFunc("/zones", func(w http.ResponseWriter, r *http.Request) {
-		err := partialTmpl.Execute(w, deviceInfoList)
+		err := partialTmpl.Execute(w, currentPartialData())
 		if err != nil {
 			log.Printf("[ERROR] error execute partial template")
 		}
 	})
+	// HTTP handler to search the device event journal, e.g. /api/events?from=2024-05-01T00:00:00Z&user=admin,
+	// behind the read auth as the journal tells who disarmed when and from where
+	http.HandleFunc("GET /api/events", readAuth(func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseEventQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		records, err := hik.SearchEvents(r.Context(), q.From, q.To, q.Filter)
+		if err != nil {
+			log.Printf("[ERROR] event search failed: %v", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(records); err != nil {
+			log.Printf("[ERROR] error encoding events: %v", err)
+		}
+	}))
+
+	// HTTP handler to browse the device event journal
+	http.HandleFunc("GET /history", readAuth(func(w http.ResponseWriter, r *http.Request) {
+		data := struct {
+			Query   eventQuery
+			Records []hikaxprogo.EventLogRecord
+			Error   string
+		}{}
+		q, err := parseEventQuery(r)
+		if err == nil {
+			data.Records, err = hik.SearchEvents(r.Context(), q.From, q.To, q.Filter)
+		}
+		if err != nil {
+			data.Error = err.Error()
+		}
+		data.Query = q
+		err = historyTmpl.Execute(w, data)
+		if err != nil {
+			log.Printf("[ERROR] error execute history template")
+		}
+	}))
+
+	// HTTP handlers to view and update the fault check policy of the device
+	// behind the command auth, a form posted by a foreign page could rewrite the policy
+	http.HandleFunc("GET /admin/faults", commandAuth(func(w http.ResponseWriter, r *http.Request) {
+		renderFaults(r.Context(), w, faultsTmpl, r.URL.Query().Get("msg"))
+	}))
+	http.HandleFunc("POST /admin/faults", commandAuth(func(w http.ResponseWriter, r *http.Request) {
+		if err := updateFaultCheck(r); err != nil {
+			log.Printf("[ERROR] fault check update failed: %v", err)
+			renderFaults(r.Context(), w, faultsTmpl, err.Error())
+			return
+		}
+		http.Redirect(w, r, "/admin/faults?msg=saved", http.StatusSeeOther)
+	}))
+
+	// HTTP handlers to arm and disarm an area, area 0 means all areas, mode is "away" or "stay"
+	http.HandleFunc("POST /api/areas/{id}/arm", commandAuth(func(w http.ResponseWriter, r *http.Request) {
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = hikaxprogo.ArmingAway
+		}
+		handleCommand(w, r, func(ctx context.Context, id int) error { return armArea(ctx, id, mode) })
+	}))
+	http.HandleFunc("POST /api/areas/{id}/disarm", commandAuth(func(w http.ResponseWriter, r *http.Request) {
+		handleCommand(w, r, func(ctx context.Context, id int) error { return armArea(ctx, id, hikaxprogo.ArmingDisarm) })
+	}))
+
+	// HTTP handlers to bypass a zone and to recover it from bypass
+	http.HandleFunc("POST /api/zones/{id}/bypass", commandAuth(func(w http.ResponseWriter, r *http.Request) {
+		handleCommand(w, r, func(ctx context.Context, id int) error { return bypassZone(ctx, id, true) })
+	}))
+	http.HandleFunc("POST /api/zones/{id}/recover", commandAuth(func(w http.ResponseWriter, r *http.Request) {
+		handleCommand(w, r, func(ctx context.Context, id int) error { return bypassZone(ctx, id, false) })
+	}))
+
+	// HTTP handlers to control relay outputs, pulse takes the length as ?seconds=N
+	http.HandleFunc("POST /api/relays/{id}/on", commandAuth(func(w http.ResponseWriter, r *http.Request) {
+		handleCommand(w, r, func(ctx context.Context, id int) error { return switchRelay(ctx, id, true) })
+	}))
+	http.HandleFunc("POST /api/relays/{id}/off", commandAuth(func(w http.ResponseWriter, r *http.Request) {
+		handleCommand(w, r, func(ctx context.Context, id int) error { return switchRelay(ctx, id, false) })
+	}))
+	http.HandleFunc("POST /api/relays/{id}/toggle", commandAuth(func(w http.ResponseWriter, r *http.Request) {
+		handleCommand(w, r, toggleRelay)
+	}))
+	http.HandleFunc("POST /api/relays/{id}/pulse", commandAuth(func(w http.ResponseWriter, r *http.Request) {
+		seconds, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+		if err != nil || seconds <= 0 || seconds > maxPulseSeconds {
+			http.Error(w, fmt.Sprintf("invalid seconds, allowed are 1 to %d", maxPulseSeconds), http.StatusBadRequest)
+			return
+		}
+		handleCommand(w, r, func(ctx context.Context, id int) error { return pulseRelay(ctx, id, seconds) })
+	}))
+
 	http.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
@@ -69,9 +181,227 @@ func httpPoller() error {
 	log.Printf("[DEBUG] listen address %s", addr)
 	// Start the HTTP server
 	log.Printf("[INFO] Server started at : %v", addr)
-	err = http.ListenAndServe(addr, nil)
-	if err != nil {
+	server := &http.Server{Addr: addr, BaseContext: func(net.Listener) context.Context { return ctx }}
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			log.Printf("[WARN] error stopping the server %v", err)
+		}
+	}()
+	err = server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("error starting the server %v", err)
 	}
 	return nil
 }
+
+// partialData is the data of the partial template
+type partialData struct {
+	Devices    []DeviceInfo
+	Areas      []AreaInfo
+	Networks   []NetworkInfo
+	ClockDrift *int // shown on the panel row
+	Commands   bool
+}
+
+// currentPartialData returns the latest device data for the partial template
+func currentPartialData() partialData {
+	mu.Lock()
+	defer mu.Unlock()
+	return partialData{
+		Devices:    deviceInfoList,
+		Areas:      areaInfoList,
+		Networks:   networkInfoList,
+		ClockDrift: clockDrift,
+		Commands:   opts.HTTPCommands,
+	}
+}
+
+// handleCommand runs a device command for the {id} of the request path and reports the result
+func handleCommand(w http.ResponseWriter, r *http.Request, cmd func(ctx context.Context, id int) error) {
+	id, err := parseID(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := cmd(r.Context(), id); err != nil {
+		log.Printf("[ERROR] command %s failed: %v", r.URL.Path, err)
+		var refused *refusedError
+		if errors.As(err, &refused) {
+			// report every reason on its own line
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprintln(w, "arming refused:")
+			for _, reason := range refused.reasons {
+				_, _ = fmt.Fprintln(w, reason)
+			}
+			return
+		}
+		http.Error(w, err.Error(), commandStatus(err))
+		return
+	}
+	_, _ = fmt.Fprintln(w, "ok")
+}
+
+// commandAuth guards a command handler: commands must be enabled with --http-commands,
+// the caller must pass basic auth and the request must not come from a foreign web page,
+// as browsers send cached credentials and simple cross-origin POSTs without asking
+func commandAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !opts.HTTPCommands {
+			http.Error(w, "commands are disabled, start with --http-commands", http.StatusForbidden)
+			return
+		}
+		if !sameOrigin(r) {
+			log.Printf("[WARN] cross-origin command %s from %s refused", r.URL.Path, r.Header.Get("Origin"))
+			http.Error(w, "cross-origin request refused", http.StatusForbidden)
+			return
+		}
+		if !basicAuth(w, r) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// readAuth guards a handler that only reads sensitive data or is expensive for the device,
+// it needs --http-password and basic auth but not --http-commands
+func readAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if opts.HTTPPassword == "" {
+			http.Error(w, "event history is disabled, start with --http-password", http.StatusForbidden)
+			return
+		}
+		if !basicAuth(w, r) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// basicAuth checks the HTTP credentials and asks for them when they don't match
+func basicAuth(w http.ResponseWriter, r *http.Request) bool {
+	user, pass, ok := r.BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(opts.HTTPUser)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(pass), []byte(opts.HTTPPassword)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="hikhello", charset="UTF-8"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// sameOrigin reports whether the request comes from our own pages or from a non-browser client
+func sameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// curl and other clients don't send an origin, browsers always do for POST
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// commandStatus maps the refusal of the device to the HTTP status of the command
+func commandStatus(err error) int {
+	switch {
+	case errors.Is(err, hikaxprogo.ErrInvalidParameter), errors.Is(err, hikaxprogo.ErrInvalidConfig):
+		return http.StatusBadRequest
+	case errors.Is(err, hikaxprogo.ErrNotSupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, hikaxprogo.ErrDeviceBusy), errors.Is(err, hikaxprogo.ErrAccountLocked):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+// maxEventLimit caps the records of an event search, every 50 records cost the device a log search page
+const maxEventLimit = 500
+
+// eventQuery is a search of the device event journal
+type eventQuery struct {
+	From   time.Time
+	To     time.Time
+	Filter hikaxprogo.EventFilter
+}
+
+// parseEventQuery reads from, to, major, minor, user and limit query parameters,
+// the time range defaults to the last 24 hours and the limit to maxEventLimit
+func parseEventQuery(r *http.Request) (eventQuery, error) {
+	q := eventQuery{To: time.Now(), Filter: hikaxprogo.EventFilter{Limit: maxEventLimit}}
+	q.From = q.To.Add(-24 * time.Hour)
+	params := r.URL.Query()
+	var err error
+	if v := params.Get("from"); v != "" {
+		if q.From, err = parseQueryTime(v); err != nil {
+			return q, err
+		}
+	}
+	if v := params.Get("to"); v != "" {
+		if q.To, err = parseQueryTime(v); err != nil {
+			return q, err
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Filter.Limit, err = strconv.Atoi(v); err != nil || q.Filter.Limit < 1 || q.Filter.Limit > maxEventLimit {
+			return q, fmt.Errorf("invalid limit %q, allowed are 1 to %d", v, maxEventLimit)
+		}
+	}
+	q.Filter.Major = params.Get("major")
+	q.Filter.Minor = params.Get("minor")
+	q.Filter.User = params.Get("user")
+	return q, nil
+}
+
+// parseQueryTime accepts RFC 3339 and the local time of HTML datetime-local inputs
+func parseQueryTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04", v, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("invalid time %q", v)
+	}
+	return t, nil
+}
+
+// renderFaults shows the fault check policy of the device with an optional message
+func renderFaults(ctx context.Context, w http.ResponseWriter, tmpl *template.Template, msg string) {
+	data := struct {
+		Config  hikaxprogo.FaultCheckCfg
+		Message string
+	}{Message: msg}
+	cfg, err := hik.GetFaultCheckConfig(ctx)
+	if err != nil {
+		data.Message = err.Error()
+	}
+	data.Config = cfg
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("[ERROR] error execute faults template")
+	}
+}
+
+// updateFaultCheck applies the fault check form to the device policy,
+// every fault type has check-<type>, arm-<type> and notify-<type> checkboxes
+func updateFaultCheck(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	cfg, err := hik.GetFaultCheckConfig(r.Context())
+	if err != nil {
+		return err
+	}
+	cfg.Enabled = r.PostForm.Get("enabled") == "on"
+	for _, t := range cfg.TypeList {
+		f := t.Type
+		f.Check = r.PostForm.Get("check-"+f.Type) == "on"
+		f.PreventArming = r.PostForm.Get("arm-"+f.Type) == "on"
+		f.Notify = r.PostForm.Get("notify-"+f.Type) == "on"
+		cfg.SetFault(f)
+	}
+	log.Printf("[INFO] update fault check policy")
+	return hik.SetFaultCheckConfig(r.Context(), cfg)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPartialTemplate renders a row of every table, template fields are only resolved on execution
+func TestPartialTemplate(t *testing.T) {
+	tmpl, err := template.ParseFiles(filepath.Join("..", "templates", "partial.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	drift := 42
+	data := partialData{
+		Devices: []DeviceInfo{
+			{Type: "panel", Name: "panel", Status: "wired", Power: "mains"},
+			{Type: "zone", ID: 1, Name: "door", Status: "online"},
+			{Type: "relay", ID: 2, Name: "gate", Status: "online", State: "ON"},
+		},
+		Areas:      []AreaInfo{{ID: 1, Name: "house", Arming: "disarm", Public: "2,3"}},
+		Networks:   []NetworkInfo{{ID: 1, IP: "192.168.1.10", SSID: "home", RSSI: -60}},
+		ClockDrift: &drift,
+		Commands:   true,
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"wired (mains), clock drift 42s", "/api/relays/2/toggle", "192.168.1.10", "home (-60 dBm)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered partial lacks %q", want)
+		}
+	}
+	if strings.Count(out, "clock drift") != 1 {
+		t.Errorf("clock drift shown on other rows than the panel")
+	}
+}
+
+func TestParseEventQueryLimit(t *testing.T) {
+	tests := []struct {
+		query string
+		limit int
+		ok    bool
+	}{
+		{"", maxEventLimit, true},
+		{"limit=50", 50, true},
+		{"limit=0", 0, false},
+		{"limit=-1", 0, false},
+		{fmt.Sprintf("limit=%d", maxEventLimit+1), 0, false},
+		{"limit=many", 0, false},
+	}
+	for _, tt := range tests {
+		q, err := parseEventQuery(httptest.NewRequest("GET", "/api/events?"+tt.query, nil))
+		if (err == nil) != tt.ok || (tt.ok && q.Filter.Limit != tt.limit) {
+			t.Errorf("%q: got limit %d, %v", tt.query, q.Filter.Limit, err)
+		}
+	}
+}
+
+func TestReadAuth(t *testing.T) {
+	defer func(user, pass string) { opts.HTTPUser, opts.HTTPPassword = user, pass }(opts.HTTPUser, opts.HTTPPassword)
+	handler := readAuth(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		password   string
+		user, pass string
+		want       int
+	}{
+		{"", "", "", http.StatusForbidden},
+		{"secret", "", "", http.StatusUnauthorized},
+		{"secret", "admin", "wrong", http.StatusUnauthorized},
+		{"secret", "admin", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		opts.HTTPUser, opts.HTTPPassword = "admin", tt.password
+		r := httptest.NewRequest("GET", "/history", nil)
+		if tt.user != "" {
+			r.SetBasicAuth(tt.user, tt.pass)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != tt.want {
+			t.Errorf("password %q, auth %s/%s: got %d, want %d", tt.password, tt.user, tt.pass, w.Code, tt.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@
 // 1. Fetching data: The `fetchData` function periodically fetches data from the Hikvision AX device and stores it in the `deviceInfoList` slice.
 // 2. HTTP poller: The `httpPoller` function listens for HTTP requests and responds with the latest device data.
 // 3. MQTT poller: The `mqttPoller` function publishes the latest device data to an MQTT broker.
+// 4. Alert stream: The optional `streamAlerts` function forwards real-time device events to MQTT and triggers an immediate fetch.
 
 // The program is configured using command-line flags and environment variables, which are defined in the `opts` struct. The main steps are:
 
@@ -19,9 +20,16 @@
 package main
 
 import (
+	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
 
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 
 	"sync"
 	"time"
@@ -35,14 +43,33 @@ var revision = "latest"
 var opts struct {
 	HttpListen string `short:"l" long:"listen" env:"LISTEN" description:"listen on host:port" default:"0.0.0.0:8080"`
 
+	HTTPCommands bool   `long:"http-commands" env:"HTTP_COMMANDS" description:"allow commands (arming, bypass, relays, fault policy) over HTTP"`
+	HTTPUser     string `long:"http-user" env:"HTTP_USER" description:"basic auth user of the HTTP commands" default:"admin"`
+	HTTPPassword string `long:"http-password" env:"HTTP_PASSWORD" description:"basic auth password of the HTTP commands and the event history, required with --http-commands"`
+
 	HIKAX struct {
 		Host     string `long:"host" env:"HIK_HOST" description:"host of the Hikvision AX device" required:"true"`
-		Port     string `long:"port" env:"HIK_PORT" description:"port of the device" default:"80"`
+		Port     string `long:"port" env:"HIK_PORT" description:"port of the device, overrides a port given with the host, 80 or 443 with TLS by default"`
 		Username string `long:"username" env:"HIK_USERNAME" description:"username to access the device" required:"true"`
 		Password string `long:"password" env:"HIK_PASSWORD" description:"password to access the device" required:"true"`
+		Timeout  uint   `long:"timeout" env:"HIK_TIMEOUT" description:"timeout of a polling cycle in seconds" default:"30"`
+
+		Auth string `long:"auth" env:"HIK_AUTH" description:"authentication, digest for older firmware" choice:"auto" choice:"session" choice:"digest" default:"auto"`
+
+		TLS            bool   `long:"tls" env:"HIK_TLS" description:"connect to the device over HTTPS"`
+		TLSCA          string `long:"tls-ca" env:"HIK_TLS_CA" description:"PEM bundle of the CAs to verify the device certificate with"`
+		TLSFingerprint string `long:"tls-fingerprint" env:"HIK_TLS_FINGERPRINT" description:"SHA-256 fingerprint of the pinned device certificate"`
+		TLSInsecure    bool   `long:"tls-insecure" env:"HIK_TLS_INSECURE" description:"accept any device certificate"`
 	} `group:"hikax" namespace:"hikax" env-namespace:"HIKAX"`
 
 	PollingTime uint `long:"polling-time" env:"POLLING_TIME" description:"polling time in seconds" default:"10"`
+	AlertStream bool `long:"alert-stream" env:"ALERT_STREAM" description:"subscribe to the device alert stream for real-time events"`
+
+	Clock struct {
+		Check    bool `long:"check" env:"CHECK" description:"compare the device time with the host clock every polling cycle"`
+		Sync     bool `long:"sync" env:"SYNC" description:"set the device time when it drifts more than max-drift, implies check, this switches the device from NTP to manual time for good"`
+		MaxDrift uint `long:"max-drift" env:"MAX_DRIFT" description:"allowed clock drift in seconds" default:"30"`
+	} `group:"clock" namespace:"clock" env-namespace:"CLOCK"`
 
 	Dbg  bool `long:"dbg" env:"DEBUG" description:"debug mode"`
 	MQTT struct {
@@ -63,13 +90,42 @@ type DeviceInfo struct {
 	Signal      int
 	Temperature int
 	ChargeValue int
+	Bypassed    bool
+	Status      string
+	Tamper      bool
+	Power       string
+	State       string
+}
+
+type AreaInfo struct {
+	ID     int
+	Name   string
+	Arming string
+	Alarm  bool
+	Fault  bool
+	Delay  int
+	Public string // linked areas of a public area, e.g. "1,2"
+}
+
+type NetworkInfo struct {
+	ID      int
+	IP      string
+	MAC     string
+	DHCP    bool
+	Gateway string
+	DNS     string
+	Link    string
+	SSID    string
+	RSSI    int
 }
 
 type HIKAXAuth struct {
-	Host  string
-	Port  string
-	Login string
-	Pass  string
+	Host    string
+	Port    string
+	Login   string
+	Pass    string
+	Timeout time.Duration
+	Options []hikaxprogo.Option // TLS and authentication configuration
 }
 type MQTTConfig struct {
 	Host        string
@@ -82,30 +138,67 @@ type MQTTConfig struct {
 }
 
 var deviceInfoList []DeviceInfo
+var areaInfoList []AreaInfo
+var networkInfoList []NetworkInfo
+var clockDrift *int // seconds the device clock is ahead of the host clock, nil until checked
 var mu sync.Mutex
 var dataChangedToHTTP = make(chan bool)
 var dataChangedToMQTT = make(chan bool)
+var refreshData = make(chan bool, 1)
+var alertToMQTT = make(chan hikaxprogo.AlertEvent, 16)
 
 // var dataChangedToMQTT = make(chan bool)
 var wg = sync.WaitGroup{}
 var pollingTime time.Duration
 var hikAXAuth HIKAXAuth
+var hik *hikaxprogo.HikISAPI
 var mqttConfig MQTTConfig
 
-func fetchData() {
+func fetchData(ctx context.Context) {
 	for {
 
 		log.Printf("[DEBUG] Fetching new data from the device...")
-		hik := hikaxprogo.New(hikAXAuth.Host, hikAXAuth.Port, hikAXAuth.Login, hikAXAuth.Pass)
-		zoneList, err := hik.ZoneStatus()
+		// a hung device must not block polling forever
+		cycleCtx, cancel := context.WithTimeout(ctx, hikAXAuth.Timeout)
+		zoneList, err := hik.ZoneStatus(cycleCtx)
 		if err != nil {
 			log.Printf("[ERROR] %v", err)
 		}
-		exDev, err := hik.ExDevData()
+		exDev, err := hik.ExDevData(cycleCtx)
 		if err != nil {
 			log.Printf("[ERROR] %v", err)
 		}
+		subSystems, err := hik.SubSystemStatus(cycleCtx)
+		if err != nil && !errors.Is(err, hikaxprogo.ErrNotSupported) {
+			log.Printf("[ERROR] %v", err)
+		}
+		publicAreas, err := hik.PublicSubSystems(cycleCtx)
+		if err != nil && !errors.Is(err, hikaxprogo.ErrNotSupported) {
+			log.Printf("[ERROR] %v", err)
+		}
+		interfaces, err := hik.NetworkInterfaces(cycleCtx)
+		if err != nil && !errors.Is(err, hikaxprogo.ErrNotSupported) {
+			log.Printf("[ERROR] %v", err)
+		}
+		host, err := hik.HostStatus(cycleCtx)
+		if err != nil && !errors.Is(err, hikaxprogo.ErrNotSupported) {
+			log.Printf("[ERROR] %v", err)
+		}
 		var newDeviceInfoList []DeviceInfo
+		if err == nil {
+			newDeviceInfoList = append(newDeviceInfoList, panelInfo(host.HostStatus))
+		}
+		// the clock doesn't depend on the host status, panels without it are checked too
+		newClockDrift := clockDrift
+		if opts.Clock.Check {
+			drift, err := checkClock(cycleCtx)
+			if err != nil {
+				log.Printf("[ERROR] %v", err)
+			} else {
+				seconds := int(drift.Round(time.Second) / time.Second)
+				newClockDrift = &seconds
+			}
+		}
 		for _, zone := range zoneList.Zones {
 			deviceInfo := DeviceInfo{
 				Type:        "zone",
@@ -114,6 +207,9 @@ func fetchData() {
 				Signal:      zone.Zone.RealSignal,
 				Temperature: zone.Zone.Temperature,
 				ChargeValue: zone.Zone.ChargeValue,
+				Bypassed:    zone.Zone.Bypassed,
+				Status:      zone.Zone.Status,
+				Tamper:      zone.Zone.TamperEvident,
 			}
 			newDeviceInfoList = append(newDeviceInfoList, deviceInfo)
 		}
@@ -126,10 +222,61 @@ func fetchData() {
 				Signal:      siren.Siren.RealSignal,
 				Temperature: siren.Siren.Temperature,
 				ChargeValue: siren.Siren.ChargeValue,
+				Status:      siren.Siren.Status,
+				Tamper:      siren.Siren.TamperEvident,
 			}
 			newDeviceInfoList = append(newDeviceInfoList, deviceInfo)
 		}
-		var dChanged = false
+		for _, keypad := range exDev.ExDevStatus.KeypadList {
+			newDeviceInfoList = append(newDeviceInfoList, peripheralInfo("keypad", keypad.Keypad.Peripheral))
+		}
+		for _, repeater := range exDev.ExDevStatus.RepeaterList {
+			newDeviceInfoList = append(newDeviceInfoList, peripheralInfo("repeater", repeater.Repeater.Peripheral))
+		}
+		for _, remote := range exDev.ExDevStatus.RemoteList {
+			newDeviceInfoList = append(newDeviceInfoList, peripheralInfo("keyfob", remote.Remote.Peripheral))
+		}
+		for _, module := range exDev.ExDevStatus.OutputModList {
+			newDeviceInfoList = append(newDeviceInfoList, peripheralInfo("relaymodule", module.OutputModule.Peripheral))
+		}
+		for _, output := range exDev.ExDevStatus.OutputList {
+			deviceInfo := peripheralInfo("relay", output.Output.Peripheral)
+			deviceInfo.State = switchState(output.Output.On())
+			newDeviceInfoList = append(newDeviceInfoList, deviceInfo)
+		}
+		var newAreaInfoList []AreaInfo
+		var areas []hikaxprogo.SubSys
+		for _, area := range subSystems.SubSysList {
+			areas = append(areas, area.SubSys)
+		}
+		for _, area := range areas {
+			if !area.Enabled {
+				continue
+			}
+			areaInfo := AreaInfo{
+				ID:     area.ID,
+				Name:   area.Name,
+				Arming: area.Arming,
+				Alarm:  area.Alarm,
+				Fault:  area.Fault,
+				Delay:  area.DelayTime,
+			}
+			// a public area follows the state of its linked private areas
+			for _, public := range publicAreas {
+				if public.Enabled && public.ID == area.ID {
+					areaInfo.Arming = public.DerivedArming(areas)
+					areaInfo.Public = joinIDs(public.LinkedSubSystem)
+				}
+			}
+			newAreaInfoList = append(newAreaInfoList, areaInfo)
+		}
+		cancel()
+
+		var newNetworkInfoList []NetworkInfo
+		for _, iface := range interfaces {
+			newNetworkInfoList = append(newNetworkInfoList, networkInfo(iface))
+		}
+		var dChanged = !slices.Equal(areaInfoList, newAreaInfoList) || !slices.Equal(networkInfoList, newNetworkInfoList)
 		if len(deviceInfoList) != len(newDeviceInfoList) {
 			dChanged = true
 		} else {
@@ -147,22 +294,162 @@ func fetchData() {
 				case d.ChargeValue != newDeviceInfoList[i].ChargeValue:
 					dChanged = true
 					break
+				case d.Bypassed != newDeviceInfoList[i].Bypassed:
+					dChanged = true
+					break
+				case d.Status != newDeviceInfoList[i].Status:
+					dChanged = true
+					break
+				case d.Tamper != newDeviceInfoList[i].Tamper:
+					dChanged = true
+					break
+				case d.Power != newDeviceInfoList[i].Power:
+					dChanged = true
+					break
+				case d.State != newDeviceInfoList[i].State:
+					dChanged = true
+					break
 				}
 
 			}
 		}
+		if (clockDrift == nil) != (newClockDrift == nil) || (newClockDrift != nil && *clockDrift != *newClockDrift) {
+			dChanged = true
+		}
 		if dChanged {
 			mu.Lock()
+			clockDrift = newClockDrift
 			deviceInfoList = newDeviceInfoList
+			areaInfoList = newAreaInfoList
+			networkInfoList = newNetworkInfoList
 			mu.Unlock()
-			dataChangedToHTTP <- true
-			dataChangedToMQTT <- true
+			for _, ch := range []chan bool{dataChangedToHTTP, dataChangedToMQTT} {
+				select {
+				case ch <- true:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		// Sleep for a specific interval or until an alert asks to fetch data again
+		select {
+		case <-time.After(pollingTime):
+		case <-refreshData:
+		case <-ctx.Done():
+			return
 		}
-		// Sleep for a specific interval before fetching data again
-		time.Sleep(pollingTime)
 
 	}
 }
+
+// panelInfo describes the control panel as a device, its status is the active communication link
+func panelInfo(host hikaxprogo.Host) DeviceInfo {
+	panel := DeviceInfo{
+		Type:   "panel",
+		ID:     0,
+		Name:   "panel",
+		Status: host.ActiveLink(),
+		Tamper: host.TamperEvident,
+		Power:  "battery",
+	}
+	if host.ACConnect {
+		panel.Power = "mains"
+	}
+	switch panel.Status {
+	case "wifi":
+		panel.Signal = host.WiFi.Signal
+	case "mobile":
+		panel.Signal = host.Mobile.Signal
+	}
+	if len(host.BatteryList) > 0 {
+		panel.ChargeValue = host.BatteryList[0].Battery.ChargeValue
+	}
+	return panel
+}
+
+// checkClock returns how far the device clock is ahead of the host clock,
+// the device time is set from the host clock when the drift exceeds the allowed maximum and sync is enabled
+func checkClock(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	clock, err := hik.DeviceTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	deviceTime, err := clock.Time()
+	if err != nil {
+		return 0, err
+	}
+	// compare against the middle of the request round trip
+	drift := deviceTime.Sub(start.Add(time.Since(start) / 2))
+	maxDrift := time.Duration(opts.Clock.MaxDrift) * time.Second
+	if drift < maxDrift && drift > -maxDrift {
+		return drift, nil
+	}
+	log.Printf("[WARN] device clock drift %v exceeds %v", drift.Round(time.Second), maxDrift)
+	if !opts.Clock.Sync {
+		return drift, nil
+	}
+	if err := hik.SetDeviceTime(ctx, time.Now()); err != nil {
+		return drift, fmt.Errorf("sync device time: %w", err)
+	}
+	log.Printf("[INFO] device time synchronized")
+	return 0, nil
+}
+
+// networkInfo describes a network interface of the device for diagnostics
+func networkInfo(iface hikaxprogo.NetworkInterface) NetworkInfo {
+	info := NetworkInfo{
+		ID:      iface.ID,
+		IP:      iface.IPAddress.Address,
+		MAC:     iface.Link.MACAddress,
+		DHCP:    iface.IPAddress.DHCP(),
+		Gateway: iface.IPAddress.Gateway,
+		DNS:     iface.IPAddress.PrimaryDNS,
+		Link:    iface.Link.Status,
+	}
+	if iface.IPAddress.SecondaryDNS != "" {
+		info.DNS += "," + iface.IPAddress.SecondaryDNS
+	}
+	if iface.Wireless != nil && iface.Wireless.Enabled {
+		info.SSID = iface.Wireless.SSID
+		info.RSSI = iface.Wireless.RSSI
+	}
+	return info
+}
+
+// peripheralInfo describes a wireless peripheral the same way as a siren
+func peripheralInfo(kind string, p hikaxprogo.Peripheral) DeviceInfo {
+	return DeviceInfo{
+		Type:        kind,
+		ID:          p.ID,
+		Name:        p.Name,
+		Signal:      p.RealSignal,
+		Temperature: p.Temperature,
+		ChargeValue: p.ChargeValue,
+		Status:      p.Status,
+		Tamper:      p.TamperEvident,
+	}
+}
+
+// streamAlerts forwards real-time panel events to MQTT and triggers an immediate refresh
+func streamAlerts(ctx context.Context) {
+	for ev := range hik.AlertStream(ctx) {
+		switch ev.Kind {
+		case hikaxprogo.EventStreamError:
+			log.Printf("[WARN] alert stream: %v", ev.Err)
+			continue
+		case hikaxprogo.EventHeartbeat:
+			continue
+		}
+		log.Printf("[INFO] alert %s: code %d, area %d, zone %d, %s", ev.Kind, ev.Code, ev.SubSystem, ev.Zone, ev.Description)
+		select {
+		case alertToMQTT <- ev:
+		default:
+			log.Printf("[WARN] alert %s dropped, mqtt is busy", ev.Kind)
+		}
+		requestRefresh()
+	}
+}
 func main() {
 	fmt.Printf("hikhello %s\n", revision)
 	p := flags.NewParser(&opts, flags.PrintErrors|flags.PassDoubleDash|flags.HelpFlag)
@@ -177,6 +464,16 @@ func main() {
 	pollingTime = setPollingTime(opts.PollingTime)
 	err := error(nil)
 	hikAXAuth, err = setHIKAXAuth()
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	// syncing needs the drift of the check
+	if opts.Clock.Sync {
+		opts.Clock.Check = true
+	}
+	if opts.HTTPCommands && opts.HTTPPassword == "" {
+		log.Fatalf("[ERROR] --http-password is required with --http-commands")
+	}
 	mqttConfig, err = setMQTTConfig()
 
 	if err != nil {
@@ -192,15 +489,24 @@ func main() {
 }
 
 func run() error {
+	hik = hikaxprogo.New(hikAXAuth.Host, hikAXAuth.Port, hikAXAuth.Login, hikAXAuth.Pass, hikAXAuth.Options...)
+
+	// Stop everything on SIGINT or SIGTERM, this cancels pending device requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Start the data fetching goroutine
-	go fetchData()
+	go fetchData(ctx)
+	if opts.AlertStream {
+		go streamAlerts(ctx)
+	}
 	err := error(nil)
 	// Start the HTTP polling goroutine
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		log.Printf("[INFO] Starting HTTP poller on %s", listenAddress(opts.HttpListen))
-		err = httpPoller()
+		err = httpPoller(ctx)
 	}()
 
 	// Start the MQTT polling goroutine
@@ -208,11 +514,15 @@ func run() error {
 	go func() {
 		defer wg.Done()
 		log.Print("[INFO] Starting MQTT poller on " + mqttConfig.Host + ":" + mqttConfig.Port)
-		err = mqttPoller(mqttConfig)
+		err = mqttPoller(ctx, mqttConfig)
 	}()
 
 	wg.Wait()
 
+	// free the session slot on the device
+	if cerr := hik.Close(); cerr != nil {
+		log.Printf("[WARN] logout failed: %v", cerr)
+	}
 	if err != nil {
 		return err
 	}
@@ -281,9 +591,22 @@ func setHIKAXAuth() (HIKAXAuth, error) {
 	if opts.HIKAX.Host == "" || opts.HIKAX.Username == "" || opts.HIKAX.Password == "" {
 		return HIKAXAuth{}, fmt.Errorf("[ERROR] HIKAX host, username and password are required")
 	}
+	options, err := tlsOptions()
+	if err != nil {
+		return HIKAXAuth{}, err
+	}
+	hikAuth.Options = options
+	switch opts.HIKAX.Auth {
+	case "session":
+		hikAuth.Options = append(hikAuth.Options, hikaxprogo.WithAuth(hikaxprogo.AuthSession))
+	case "digest":
+		hikAuth.Options = append(hikAuth.Options, hikaxprogo.WithAuth(hikaxprogo.AuthDigest))
+	}
+	// without a port the library keeps the port of the host or uses the default of the scheme
 	hikAuth.Port = opts.HIKAX.Port
-	if hikAuth.Port == "" {
-		hikAuth.Port = "80"
+	hikAuth.Timeout = time.Duration(opts.HIKAX.Timeout) * time.Second
+	if hikAuth.Timeout == 0 {
+		hikAuth.Timeout = 30 * time.Second
 	}
 	hikAuth.Host = opts.HIKAX.Host
 	hikAuth.Login = opts.HIKAX.Username
@@ -291,3 +614,30 @@ func setHIKAXAuth() (HIKAXAuth, error) {
 	return hikAuth, nil
 
 }
+
+// tlsOptions returns the client options of the --hikax.tls-* flags, none for plain HTTP
+func tlsOptions() ([]hikaxprogo.Option, error) {
+	var options []hikaxprogo.Option
+	if opts.HIKAX.TLS || strings.HasPrefix(opts.HIKAX.Host, "https://") {
+		options = append(options, hikaxprogo.WithTLS())
+	}
+	if opts.HIKAX.TLSCA != "" {
+		pem, err := os.ReadFile(opts.HIKAX.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA bundle %s", opts.HIKAX.TLSCA)
+		}
+		options = append(options, hikaxprogo.WithRootCAs(pool))
+	}
+	if opts.HIKAX.TLSFingerprint != "" {
+		options = append(options, hikaxprogo.WithPinnedFingerprint(opts.HIKAX.TLSFingerprint))
+	}
+	if opts.HIKAX.TLSInsecure {
+		log.Printf("[WARN] the device certificate is not verified")
+		options = append(options, hikaxprogo.WithInsecureSkipVerify())
+	}
+	return options, nil
+}
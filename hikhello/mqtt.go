@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/go-pkgz/lgr"
+	"github.com/i39/hikaxprogo"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
@@ -17,19 +23,129 @@ func publish(client mqtt.Client, topic string, payload interface{}) {
 	}
 }
 
-func mqttPoller(config MQTTConfig) error {
-	defer wg.Done()
+// publishAlert publishes a real-time panel event as JSON to the event topic
+func publishAlert(client mqtt.Client, topic string, ev hikaxprogo.AlertEvent) {
+	payload, err := json.Marshal(struct {
+		Kind        string    `json:"kind"`
+		Time        time.Time `json:"time"`
+		Code        int       `json:"code"`
+		Restore     bool      `json:"restore"`
+		Zone        int       `json:"zone"`
+		ZoneName    string    `json:"zoneName"`
+		Area        int       `json:"area"`
+		User        string    `json:"user"`
+		Description string    `json:"description"`
+	}{ev.Kind, ev.Time, ev.Code, ev.Restore, ev.Zone, ev.ZoneName, ev.SubSystem, ev.User, ev.Description})
+	if err != nil {
+		log.Printf("[ERROR] Error encoding alert: %v", err)
+		return
+	}
+	token := client.Publish(fmt.Sprintf("%s/event", topic), 0, false, payload)
+	token.Wait()
+	if token.Error() != nil {
+		log.Printf("[ERROR] Error publishing alert: %v", token.Error())
+	}
+}
+
+// publishResult publishes the outcome of a command as JSON, with the reasons when arming was refused
+func publishResult(client mqtt.Client, topic string, cmdErr error) {
+	result := struct {
+		OK      bool     `json:"ok"`
+		Error   string   `json:"error,omitempty"`
+		Reasons []string `json:"reasons,omitempty"`
+	}{OK: cmdErr == nil}
+	if cmdErr != nil {
+		result.Error = cmdErr.Error()
+		var refused *refusedError
+		if errors.As(cmdErr, &refused) {
+			result.Reasons = refused.reasons
+		}
+	}
+	payload, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("[ERROR] Error encoding result: %v", err)
+		return
+	}
+	token := client.Publish(topic, 0, false, payload)
+	token.Wait()
+	if token.Error() != nil {
+		log.Printf("[ERROR] Error publishing result: %v", token.Error())
+	}
+}
+
+// subscribeCommands subscribes to the command topics, e.g. <topic>/zone/<id>/bypass/set
+func subscribeCommands(ctx context.Context, client mqtt.Client, topic string) {
+	subscribe(ctx, client, topic, "area/+/set", func(ctx context.Context, id int, payload string) error {
+		err := armArea(ctx, id, strings.ToLower(strings.TrimSpace(payload)))
+		publishResult(client, fmt.Sprintf("%s/area/%d/result", topic, id), err)
+		return err
+	})
+	subscribe(ctx, client, topic, "zone/+/bypass/set", func(ctx context.Context, id int, payload string) error {
+		bypass, err := parseSwitch(payload)
+		if err != nil {
+			return err
+		}
+		return bypassZone(ctx, id, bypass)
+	})
+	subscribe(ctx, client, topic, "relay/+/set", func(ctx context.Context, id int, payload string) error {
+		if strings.EqualFold(strings.TrimSpace(payload), "toggle") {
+			return toggleRelay(ctx, id)
+		}
+		on, err := parseSwitch(payload)
+		if err != nil {
+			return err
+		}
+		return switchRelay(ctx, id, on)
+	})
+	subscribe(ctx, client, topic, "relay/+/pulse", func(ctx context.Context, id int, payload string) error {
+		seconds, err := strconv.Atoi(strings.TrimSpace(payload))
+		if err != nil {
+			return fmt.Errorf("invalid pulse length %q", payload)
+		}
+		return pulseRelay(ctx, id, seconds)
+	})
+}
+
+// subscribe subscribes to a command topic, the id is taken from the second level after the base topic
+func subscribe(ctx context.Context, client mqtt.Client, topic string, filter string, cmd func(ctx context.Context, id int, payload string) error) {
+	token := client.Subscribe(topic+"/"+filter, 0, func(c mqtt.Client, msg mqtt.Message) {
+		parts := strings.Split(strings.TrimPrefix(msg.Topic(), topic+"/"), "/")
+		if len(parts) < 2 {
+			return
+		}
+		// don't block the mqtt client while the device handles the command
+		go func() {
+			id, err := parseID(parts[1])
+			if err == nil {
+				err = cmd(ctx, id, string(msg.Payload()))
+			}
+			if err != nil {
+				log.Printf("[ERROR] command %s failed: %v", msg.Topic(), err)
+			}
+		}()
+	})
+	token.Wait()
+	if token.Error() != nil {
+		log.Printf("[ERROR] Error subscribing topic %s: %v", filter, token.Error())
+	}
+}
+
+func mqttPoller(ctx context.Context, config MQTTConfig) error {
 	// Configure MQTT client options
 
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(fmt.Sprintf("tcp://%s:%s", config.Host, config.Port))
-	opts.SetKeepAlive(config.KeepAlive)
-	opts.SetPingTimeout(config.PingTimeout)
-	opts.Username = config.Login
-	opts.Password = config.Pass
+	clientOpts := mqtt.NewClientOptions()
+	clientOpts.AddBroker(fmt.Sprintf("tcp://%s:%s", config.Host, config.Port))
+	clientOpts.SetKeepAlive(config.KeepAlive)
+	clientOpts.SetPingTimeout(config.PingTimeout)
+	clientOpts.Username = config.Login
+	clientOpts.Password = config.Pass
+	// (re)subscribe to command topics on every connect
+	clientOpts.SetOnConnectHandler(func(c mqtt.Client) {
+		subscribeCommands(ctx, c, config.Topic)
+	})
 
 	// Create and start an MQTT client
-	client := mqtt.NewClient(opts)
+	client := mqtt.NewClient(clientOpts)
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
 		return token.Error()
 	}
@@ -43,11 +159,50 @@ func mqttPoller(config MQTTConfig) error {
 				publish(client, fmt.Sprintf("%s/%s/%d/signal", config.Topic, d.Type, d.ID), d.Signal)
 				publish(client, fmt.Sprintf("%s/%s/%d/temperature", config.Topic, d.Type, d.ID), d.Temperature)
 				publish(client, fmt.Sprintf("%s/%s/%d/charge", config.Topic, d.Type, d.ID), d.ChargeValue)
+				publish(client, fmt.Sprintf("%s/%s/%d/status", config.Topic, d.Type, d.ID), d.Status)
+				publish(client, fmt.Sprintf("%s/%s/%d/tamper", config.Topic, d.Type, d.ID), d.Tamper)
+				if d.Type == "panel" {
+					publish(client, fmt.Sprintf("%s/panel/%d/power", config.Topic, d.ID), d.Power)
+				}
+				if d.Type == "relay" {
+					publish(client, fmt.Sprintf("%s/relay/%d/state", config.Topic, d.ID), d.State)
+				}
+				if d.Type == "zone" {
+					publish(client, fmt.Sprintf("%s/zone/%d/bypass", config.Topic, d.ID), switchState(d.Bypassed))
+				}
 			}
-
+			// published without the panel device too, panels without host status have a clock as well
+			if clockDrift != nil {
+				publish(client, fmt.Sprintf("%s/panel/0/clock_drift", config.Topic), *clockDrift)
+			}
+			for _, a := range areaInfoList {
+				publish(client, fmt.Sprintf("%s/area/%d/name", config.Topic, a.ID), a.Name)
+				publish(client, fmt.Sprintf("%s/area/%d/arming", config.Topic, a.ID), a.Arming)
+				publish(client, fmt.Sprintf("%s/area/%d/alarm", config.Topic, a.ID), a.Alarm)
+				publish(client, fmt.Sprintf("%s/area/%d/fault", config.Topic, a.ID), a.Fault)
+				publish(client, fmt.Sprintf("%s/area/%d/delay", config.Topic, a.ID), a.Delay)
+				if a.Public != "" {
+					publish(client, fmt.Sprintf("%s/area/%d/public", config.Topic, a.ID), a.Public)
+				}
+			}
+			for _, n := range networkInfoList {
+				publish(client, fmt.Sprintf("%s/diagnostics/network/%d/ip", config.Topic, n.ID), n.IP)
+				publish(client, fmt.Sprintf("%s/diagnostics/network/%d/mac", config.Topic, n.ID), n.MAC)
+				publish(client, fmt.Sprintf("%s/diagnostics/network/%d/dhcp", config.Topic, n.ID), n.DHCP)
+				publish(client, fmt.Sprintf("%s/diagnostics/network/%d/gateway", config.Topic, n.ID), n.Gateway)
+				publish(client, fmt.Sprintf("%s/diagnostics/network/%d/dns", config.Topic, n.ID), n.DNS)
+				publish(client, fmt.Sprintf("%s/diagnostics/network/%d/link", config.Topic, n.ID), n.Link)
+				if n.SSID != "" {
+					publish(client, fmt.Sprintf("%s/diagnostics/network/%d/ssid", config.Topic, n.ID), n.SSID)
+					publish(client, fmt.Sprintf("%s/diagnostics/network/%d/rssi", config.Topic, n.ID), n.RSSI)
+				}
+			}
+		case ev := <-alertToMQTT:
+			publishAlert(client, config.Topic, ev)
+		case <-ctx.Done():
+			client.Disconnect(250)
+			return nil
 		}
-		// Sleep for a specific interval before fetching data again
-		time.Sleep(pollingTime)
 
 	}
 